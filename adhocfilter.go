@@ -0,0 +1,135 @@
+package simplejson
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AdHocFilters is the set of ad hoc filters selected in the dashboard. Each filter's Condition ("AND" or "OR") specifies
+// how it is combined with the previous filter. An empty Condition is treated as "AND". As in most query languages,
+// AND takes precedence over OR.
+type AdHocFilters []AdHocFilter
+
+// Match reports whether the labels (i.e. a set of tag keys & values) match the filters. A label that is not present
+// is treated as an empty string. If there are no filters, Match returns true.
+//
+// Match compiles the filters on every call. To match many sets of labels, use Compile instead.
+func (f AdHocFilters) Match(labels map[string]string) (bool, error) {
+	m, err := f.Compile()
+	if err != nil {
+		return false, err
+	}
+	return m.Match(labels), nil
+}
+
+// Compile validates the filters and compiles them into an AdHocMatcher, which can be used to match many sets of labels
+// without re-parsing the filters (e.g. their regular expressions).
+func (f AdHocFilters) Compile() (*AdHocMatcher, error) {
+	// group the AND-ed filters, separated by OR
+	var m AdHocMatcher
+	var group []adHocCondition
+	for index, filter := range f {
+		if index > 0 {
+			switch strings.ToUpper(filter.Condition) {
+			case "", "AND":
+			case "OR":
+				m.groups = append(m.groups, group)
+				group = nil
+			default:
+				return nil, fmt.Errorf("invalid condition for key '%s': '%s'", filter.Key, filter.Condition)
+			}
+		}
+		match, err := filter.compile()
+		if err != nil {
+			return nil, err
+		}
+		group = append(group, adHocCondition{key: filter.Key, match: match})
+	}
+	if len(f) > 0 {
+		m.groups = append(m.groups, group)
+	}
+	return &m, nil
+}
+
+// AdHocMatcher is a compiled set of AdHocFilters. See AdHocFilters.Compile.
+type AdHocMatcher struct {
+	groups [][]adHocCondition
+}
+
+type adHocCondition struct {
+	key   string
+	match func(value string) bool
+}
+
+// Match reports whether the labels match the filters. A label that is not present is treated as an empty string.
+// If there are no filters, Match returns true.
+func (m *AdHocMatcher) Match(labels map[string]string) bool {
+	if len(m.groups) == 0 {
+		return true
+	}
+	for _, group := range m.groups {
+		match := true
+		for _, condition := range group {
+			if !condition.match(labels[condition.key]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the value matches the filter. Supported operators are "=" and "!=" (string comparison),
+// "<" and ">" (numeric comparison if both values are numbers, string comparison otherwise) and "=~" and "!~" (regular
+// expression that must match the whole value).
+func (f AdHocFilter) Match(value string) (bool, error) {
+	match, err := f.compile()
+	if err != nil {
+		return false, err
+	}
+	return match(value), nil
+}
+
+func (f AdHocFilter) compile() (func(value string) bool, error) {
+	switch f.Operator {
+	case "=":
+		return func(value string) bool { return value == f.Value }, nil
+	case "!=":
+		return func(value string) bool { return value != f.Value }, nil
+	case "<":
+		return func(value string) bool { return compare(value, f.Value) < 0 }, nil
+	case ">":
+		return func(value string) bool { return compare(value, f.Value) > 0 }, nil
+	case "=~", "!~":
+		re, err := regexp.Compile("^(?:" + f.Value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression for key '%s': %w", f.Key, err)
+		}
+		want := f.Operator == "=~"
+		return func(value string) bool { return re.MatchString(value) == want }, nil
+	default:
+		return nil, fmt.Errorf("invalid operator for key '%s': '%s'", f.Key, f.Operator)
+	}
+}
+
+// compare compares two values numerically if both are numbers. Otherwise, it compares them as strings.
+func compare(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
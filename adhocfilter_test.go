@@ -0,0 +1,119 @@
+package simplejson_test
+
+import (
+	"encoding/json"
+	"github.com/clambin/simplejson/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAdHocFilters_UnmarshalJSON(t *testing.T) {
+	var request simplejson.QueryRequest
+	err := json.Unmarshal([]byte(`{
+	"targets": [ { "target": "A" } ],
+	"adhocFilters": [
+		{ "key": "host", "operator": "=", "value": "host-01", "condition": "" },
+		{ "key": "cpu", "operator": ">", "value": "10", "condition": "AND" }
+	]
+}`), &request)
+	require.NoError(t, err)
+	assert.Equal(t, simplejson.AdHocFilters{
+		{Key: "host", Operator: "=", Value: "host-01"},
+		{Key: "cpu", Operator: ">", Value: "10", Condition: "AND"},
+	}, request.AdHocFilters)
+}
+
+func TestAdHocFilters_Match(t *testing.T) {
+	labels := map[string]string{"host": "host-01", "cpu": "9.5", "region": "eu-west"}
+
+	testCases := []struct {
+		name    string
+		filters simplejson.AdHocFilters
+		pass    bool
+		match   bool
+	}{
+		{name: "empty", pass: true, match: true},
+		{name: "equal", filters: simplejson.AdHocFilters{{Key: "host", Operator: "=", Value: "host-01"}}, pass: true, match: true},
+		{name: "not equal", filters: simplejson.AdHocFilters{{Key: "host", Operator: "!=", Value: "host-01"}}, pass: true, match: false},
+		{name: "less than (numeric)", filters: simplejson.AdHocFilters{{Key: "cpu", Operator: "<", Value: "10"}}, pass: true, match: true},
+		{name: "greater than (numeric)", filters: simplejson.AdHocFilters{{Key: "cpu", Operator: ">", Value: "10"}}, pass: true, match: false},
+		{name: "greater than (string)", filters: simplejson.AdHocFilters{{Key: "host", Operator: ">", Value: "host-00"}}, pass: true, match: true},
+		{name: "regex", filters: simplejson.AdHocFilters{{Key: "region", Operator: "=~", Value: "eu-.+"}}, pass: true, match: true},
+		{name: "regex is anchored", filters: simplejson.AdHocFilters{{Key: "region", Operator: "=~", Value: "eu"}}, pass: true, match: false},
+		{name: "negative regex", filters: simplejson.AdHocFilters{{Key: "region", Operator: "!~", Value: "us-.+"}}, pass: true, match: true},
+		{name: "missing label", filters: simplejson.AdHocFilters{{Key: "zone", Operator: "=", Value: ""}}, pass: true, match: true},
+		{
+			name: "and",
+			filters: simplejson.AdHocFilters{
+				{Key: "host", Operator: "=", Value: "host-01"},
+				{Key: "cpu", Operator: ">", Value: "10", Condition: "AND"},
+			},
+			pass:  true,
+			match: false,
+		},
+		{
+			name: "or",
+			filters: simplejson.AdHocFilters{
+				{Key: "host", Operator: "=", Value: "host-02"},
+				{Key: "cpu", Operator: "<", Value: "10", Condition: "OR"},
+			},
+			pass:  true,
+			match: true,
+		},
+		{
+			name: "and takes precedence over or",
+			filters: simplejson.AdHocFilters{
+				{Key: "host", Operator: "=", Value: "host-01"},
+				{Key: "region", Operator: "=", Value: "eu-west", Condition: "OR"},
+				{Key: "cpu", Operator: ">", Value: "10", Condition: "AND"},
+			},
+			pass:  true,
+			match: true,
+		},
+		{name: "invalid operator", filters: simplejson.AdHocFilters{{Key: "host", Operator: "~", Value: "host-01"}}, pass: false},
+		{name: "invalid regex", filters: simplejson.AdHocFilters{{Key: "host", Operator: "=~", Value: "host-("}}, pass: false},
+		{
+			name: "invalid condition",
+			filters: simplejson.AdHocFilters{
+				{Key: "host", Operator: "=", Value: "host-01"},
+				{Key: "cpu", Operator: ">", Value: "10", Condition: "XOR"},
+			},
+			pass: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := tt.filters.Match(labels)
+			if !tt.pass {
+				assert.Error(t, err)
+				_, err = tt.filters.Compile()
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.match, match)
+
+			m, err := tt.filters.Compile()
+			require.NoError(t, err)
+			assert.Equal(t, tt.match, m.Match(labels))
+		})
+	}
+}
+
+func BenchmarkAdHocMatcher_Match(b *testing.B) {
+	filters := simplejson.AdHocFilters{
+		{Key: "host", Operator: "=~", Value: "host-.+"},
+		{Key: "region", Operator: "!~", Value: "us-.+", Condition: "AND"},
+	}
+	labels := map[string]string{"host": "host-01", "region": "eu-west"}
+	m, err := filters.Compile()
+	require.NoError(b, err)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !m.Match(labels) {
+			b.Fatal("no match")
+		}
+	}
+}
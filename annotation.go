@@ -2,6 +2,7 @@ package simplejson
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -12,6 +13,10 @@ type AnnotationRequest struct {
 }
 
 // RequestDetails specifies which annotation should be returned.
+//
+// Query selects the target whose annotations are returned, followed by optional key=value parameters, separated by spaces
+// (e.g. "deployments env=prod"). The target can be any target registered with Register or RegisterPattern. The parameters
+// are available to the handler through TargetParams. If Query has no target, the annotations of all handlers are returned.
 type RequestDetails struct {
 	Name       string `json:"name"`
 	Datasource string `json:"datasource"`
@@ -19,6 +24,77 @@ type RequestDetails struct {
 	Query      string `json:"query"`
 }
 
+// parseAnnotationQuery parses an annotation query of the form "<target> key=value ...". It returns the target and the
+// key/value parameters. A parameter without a value has an empty value. If the query starts with a parameter, the target is empty.
+func parseAnnotationQuery(query string) (target string, params map[string]string) {
+	fields := strings.Fields(query)
+	if len(fields) > 0 && !strings.Contains(fields[0], "=") {
+		target, fields = fields[0], fields[1:]
+	}
+	if len(fields) > 0 {
+		params = make(map[string]string, len(fields))
+		for _, field := range fields {
+			key, value, _ := strings.Cut(field, "=")
+			params[key] = value
+		}
+	}
+	return target, params
+}
+
+// filterAnnotations returns the annotations that overlap with the time range (see Annotation.InRange) and that have all
+// the specified tags.
+func filterAnnotations(annotations []Annotation, r Range, tags []string) []Annotation {
+	filtered := make([]Annotation, 0, len(annotations))
+	for _, annotation := range annotations {
+		if annotation.InRange(r) && annotation.hasTags(tags) {
+			filtered = append(filtered, annotation)
+		}
+	}
+	return filtered
+}
+
+// InRange reports whether the annotation overlaps with the time range. An annotation region overlaps if any part of the
+// region falls inside the range. A zero From or To leaves the range open on that side.
+func (annotation Annotation) InRange(r Range) bool {
+	end := annotation.TimeEnd
+	if end.IsZero() {
+		end = annotation.Time
+	}
+	if !r.From.IsZero() && end.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && annotation.Time.After(r.To) {
+		return false
+	}
+	return true
+}
+
+func (annotation Annotation) hasTags(tags []string) bool {
+	for _, tag := range tags {
+		var found bool
+		for _, t := range annotation.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// annotationTags returns the tags listed in the "tags" parameter of an annotation query, e.g. "tags=deploy,prod".
+func annotationTags(params map[string]string) (tags []string) {
+	for _, tag := range strings.Split(params["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 // UnmarshalJSON unmarshalls a AnnotationRequest from JSON
 func (r *AnnotationRequest) UnmarshalJSON(b []byte) (err error) {
 	type Request2 AnnotationRequest
@@ -30,6 +106,22 @@ func (r *AnnotationRequest) UnmarshalJSON(b []byte) (err error) {
 	return err
 }
 
+// AnnotationErrorPolicy determines how the Server handles errors returned by a handler's Annotations function.
+type AnnotationErrorPolicy int
+
+const (
+	// AnnotationErrorSkip logs the error and returns the annotations of all other handlers. If all handlers fail, the
+	// response holds no annotations (with HTTP status 200), so Grafana can't tell the failure from an empty result.
+	// This is the default.
+	AnnotationErrorSkip AnnotationErrorPolicy = iota
+	// AnnotationErrorFail fails the annotations request as soon as one handler fails, with HTTP status 500 (or 504 if the
+	// handler timed out).
+	AnnotationErrorFail
+	// AnnotationErrorPartial behaves as AnnotationErrorSkip if at least one handler succeeds. If all handlers fail, the
+	// annotations request fails with HTTP status 500, so Grafana reports the failure.
+	AnnotationErrorPartial
+)
+
 // Annotation response. The annotation endpoint returns a slice of these.
 type Annotation struct {
 	Time    time.Time
@@ -69,3 +161,39 @@ func (annotation Annotation) MarshalJSON() (output []byte, err error) {
 
 	return json.Marshal(jsonResponse)
 }
+
+// AnnotationsResponse is a Query response that returns annotations, for datasources that query annotations through
+// the /query endpoint (e.g. simPod's GrafanaJsonDatasource). It is sent as a table with columns "time", "timeEnd",
+// "title", "text" and "tags". For an annotation that is not a region, timeEnd is the same as time. Tags are joined by commas.
+type AnnotationsResponse []Annotation
+
+// MarshalJSON converts an AnnotationsResponse to JSON.
+func (r AnnotationsResponse) MarshalJSON() ([]byte, error) {
+	return r.TableResponse().MarshalJSON()
+}
+
+// TableResponse returns the annotations as a TableResponse.
+func (r AnnotationsResponse) TableResponse() TableResponse {
+	timestamps := make(TimeColumn, len(r))
+	timeEnds := make(TimeColumn, len(r))
+	titles := make(StringColumn, len(r))
+	texts := make(StringColumn, len(r))
+	tags := make(StringColumn, len(r))
+	for i, annotation := range r {
+		timestamps[i] = annotation.Time
+		timeEnds[i] = annotation.TimeEnd
+		if timeEnds[i].IsZero() {
+			timeEnds[i] = annotation.Time
+		}
+		titles[i] = annotation.Title
+		texts[i] = annotation.Text
+		tags[i] = strings.Join(annotation.Tags, ",")
+	}
+	return TableResponse{Columns: []Column{
+		{Text: "time", Data: timestamps},
+		{Text: "timeEnd", Data: timeEnds},
+		{Text: "title", Data: titles},
+		{Text: "text", Data: texts},
+		{Text: "tags", Data: tags},
+	}}
+}
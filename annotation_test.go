@@ -64,3 +64,27 @@ func TestAnnotation_MarshalJSON(t *testing.T) {
 
 	assert.Equal(t, golden, body)
 }
+
+func TestAnnotationsResponse_MarshalJSON(t *testing.T) {
+	response := simplejson.AnnotationsResponse{
+		{
+			Time:  time.Date(2022, time.January, 23, 0, 0, 0, 0, time.UTC),
+			Title: "foo",
+			Text:  "bar",
+			Tags:  []string{"A", "B"},
+		},
+		{
+			Time:    time.Date(2022, time.January, 24, 0, 0, 0, 0, time.UTC),
+			TimeEnd: time.Date(2022, time.January, 24, 1, 0, 0, 0, time.UTC),
+			Title:   "snafu",
+		},
+	}
+
+	body, err := json.Marshal(response)
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"table","columns":[{"text":"time","type":"time"},{"text":"timeEnd","type":"time"},{"text":"title","type":"string"},{"text":"text","type":"string"},{"text":"tags","type":"string"}],"rows":[["2022-01-23T00:00:00Z","2022-01-23T00:00:00Z","foo","bar","A,B"],["2022-01-24T00:00:00Z","2022-01-24T01:00:00Z","snafu","",""]]}`, string(body))
+
+	body, err = json.Marshal(simplejson.AnnotationsResponse{})
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"table","columns":[{"text":"time","type":"time"},{"text":"timeEnd","type":"time"},{"text":"title","type":"string"},{"text":"text","type":"string"},{"text":"tags","type":"string"}],"rows":[]}`, string(body))
+}
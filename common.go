@@ -4,14 +4,21 @@ import "time"
 
 // Args contains common arguments used by endpoints.
 type Args struct {
-	Range        Range `json:"range"`
-	AdHocFilters []AdHocFilter
+	Range        Range        `json:"range"`
+	AdHocFilters AdHocFilters `json:"adhocFilters"`
 }
 
 // Range specified a start and end time for the data to be returned.
 type Range struct {
 	From time.Time `json:"from"`
 	To   time.Time `json:"to"`
+	Raw  RawRange  `json:"raw"`
+}
+
+// RawRange contains the time range as entered in the dashboard, e.g. "now-6h" to "now".
+type RawRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 // AdHocFilter specifies the ad hoc filters, whose keys & values are returned by the /tag-key and /tag-values endpoints.
@@ -0,0 +1,36 @@
+package simplejson
+
+import "context"
+
+type ctxKey int
+
+const (
+	targetKey ctxKey = iota
+	targetParamsKey
+)
+
+// TargetFromContext returns the Target that a Query or AnnotationsContext function is serving. This allows a handler that is registered for
+// several targets to determine which target triggered the call.
+func TargetFromContext(ctx context.Context) (Target, bool) {
+	target, ok := ctx.Value(targetKey).(Target)
+	return target, ok
+}
+
+func withTarget(ctx context.Context, target Target) context.Context {
+	return context.WithValue(ctx, targetKey, target)
+}
+
+// TargetParams returns the parameters captured from the target name, when the target is served by a handler registered
+// through RegisterPattern. For annotation requests, it also holds the key=value parameters of the annotation query.
+// Otherwise, it returns nil.
+func TargetParams(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(targetParamsKey).(map[string]string)
+	return params
+}
+
+func withTargetParams(ctx context.Context, params map[string]string) context.Context {
+	if params == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, targetParamsKey, params)
+}
@@ -0,0 +1,49 @@
+package simplejson_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/clambin/simplejson/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestTargetFromContext(t *testing.T) {
+	var lock sync.Mutex
+	targets := make(map[string]simplejson.Target)
+	h := queryHandler(func(ctx context.Context, _ simplejson.QueryRequest) (simplejson.Response, error) {
+		target, ok := simplejson.TargetFromContext(ctx)
+		require.True(t, ok)
+		lock.Lock()
+		defer lock.Unlock()
+		targets[target.RefID] = target
+		return simplejson.TimeSeriesResponse{Target: target.Name}, nil
+	})
+	r := simplejson.New(map[string]simplejson.Handler{"A": h, "B": h})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [
+		{ "target": "A", "refId": "A", "payload": { "host": "host-01" } },
+		{ "target": "B", "refId": "B", "type": "table" }
+	] }`))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[{"target":"A","datapoints":null},{"target":"B","datapoints":null}]
+`, w.Body.String())
+
+	require.Len(t, targets, 2)
+	assert.Equal(t, "A", targets["A"].Name)
+	assert.JSONEq(t, `{ "host": "host-01" }`, string(targets["A"].Payload))
+	assert.Equal(t, "B", targets["B"].Name)
+	assert.Equal(t, "table", targets["B"].Type)
+}
+
+func TestTargetFromContext_Missing(t *testing.T) {
+	_, ok := simplejson.TargetFromContext(context.Background())
+	assert.False(t, ok)
+	assert.Nil(t, simplejson.TargetParams(context.Background()))
+}
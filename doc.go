@@ -21,6 +21,25 @@ simplejson.New() creates a SimpleJSON server.  The server is implemented as an h
 
 This starts a server, listening on port 8080, with one target "my-target", served by myHandler.
 
+Targets can also be added or removed while the server is running, e.g. for targets that are discovered at runtime:
+
+	r.Register("C", &handler{})
+	r.Unregister("A")
+
+A single handler can serve many similar targets by registering it for a pattern. Patterns are regular expressions (use Glob
+to convert a glob pattern). The values captured by the pattern are available to the handler through TargetParams:
+
+	_ = r.RegisterPattern(`cpu\.(?P<host>.+)`, &cpuHandler{})
+
+	func (h *cpuHandler) Query(ctx context.Context, req simplejson.QueryRequest) (simplejson.Response, error) {
+		host := simplejson.TargetParams(ctx)["host"]
+		// build response for host
+	}
+
+To list the concrete targets of a pattern handler in the /search endpoint, the handler implements the Targets endpoint.
+Targets that don't match the pattern are ignored.
+UnregisterPattern removes a pattern handler.
+
 # Handler
 
 A handler serves incoming requests from Grafana, e.g. queries, requests for annotations or tag.
@@ -28,9 +47,10 @@ The Handler interface contains all functions a handler needs to implement. It co
 This function returns the Grafana SimpleJSON endpoints that the handler supports. Those can be:
 
   - Query()       implements the /query endpoint. handles both timeserie & table responses
-  - Annotations() implements the /annotation endpoint
+  - Annotations() implements the /annotation endpoint (use AnnotationsContext for a version that receives the request's context)
   - TagKeys()     implements the /tag-keys endpoint
   - TagValues()   implements the /tag-values endpoint
+  - Search()      implements the /search endpoint for the handler's targets
 
 Here's an example of a handler that supports timeseries queries:
 
@@ -48,6 +68,34 @@ Here's an example of a handler that supports timeseries queries:
 		return
 	}
 
+A handler registered for several targets can determine which target it is serving through TargetFromContext:
+
+	func (handler *myHandler) Query(ctx context.Context, req simplejson.QueryRequest) (simplejson.Response, error) {
+		target, _ := simplejson.TargetFromContext(ctx)
+		// build response for target.Name
+	}
+
+# Search
+
+Grafana calls the /search endpoint to list the available targets, and to resolve query template variables. By default,
+the server returns all targets that contain the search term sent by Grafana. A handler that implements the Search endpoint
+determines its own results instead, e.g. to return dynamic values for a template variable:
+
+	func (h *handler) Search(_ context.Context, term string) ([]string, error) {
+		return h.lookupHosts(term)
+	}
+
+To show a friendly label in a template variable's dropdown, while querying by ID, a handler implements the SearchValues
+endpoint instead, which returns text/value pairs:
+
+	func (h *handler) SearchValues(_ context.Context, _ string) ([]simplejson.SearchValue, error) {
+		return []simplejson.SearchValue{{Text: "host 1", Value: "id-1"}}, nil
+	}
+
+The server only returns text/value pairs when created with the WithSearchValues option. In that case, all results are sent as
+text/value pairs, so the shape of the /search response doesn't depend on which handlers returned results. Otherwise, the /search
+endpoint returns the values only.
+
 # Queries
 
 SimpleJSON supports two types of query responses: timeseries responses and table responses.
@@ -66,6 +114,9 @@ Timeseries queries return values as a list of timestamp/value tuples. Here's an
 		return
 	}
 
+NaN and infinite values are not valid JSON and are sent as null, so Grafana shows a gap. Use the WithNaNPolicy option to drop
+or clamp those values instead. A handler can also report missing values explicitly with a NullableTimeSeriesResponse.
+
 Table Queries, on the other hand, return data organized in columns and rows.  Each column needs to have the same number of rows:
 
 	func (handler *myHandler) TableQuery(_ context.Context, _ string, _ query.QueryArgs) (response *simplejson.TableResponse, err error) {
@@ -80,11 +131,31 @@ Table Queries, on the other hand, return data organized in columns and rows.  Ea
 		return
 	}
 
+Columns can also hold integers (IntColumn) or booleans (BoolColumn). To leave cells empty, use the nullable variants
+(e.g. NullableNumberColumn): nil values are sent as null.
+
+A table response with an unsupported column type, or with columns of different length, fails to marshal. Handlers can
+call TableResponse.Validate, e.g. in their unit tests, to check a response.
+
+When a query request contains multiple targets, the server processes them in parallel and returns the responses in the order
+of the request's targets. If one target fails, the context passed to the remaining targets' Query functions is cancelled.
+Use the WithMaxConcurrentQueries option to limit how many targets are processed at the same time.
+
+By default, a single failing target fails the whole query request. With the WithPartialResults option, the server returns
+the responses of the successful targets and reports each failed target as an empty timeseries with an error message.
+
+Grafana specifies the maximum number of data points that a panel can show in the request's MaxDataPoints. With the WithDownsampling
+option, the server reduces timeseries responses (including NullableTimeSeriesResponse) to that number of data points, using the selected
+algorithm (LTTB, min/max or average). Handlers can also downsample a response themselves, using TimeSeriesResponse.Downsample.
+
+The WithTimeout option bounds the context passed to a handler's Query, AnnotationsContext, TagKeys and TagValues functions, either for all
+targets or for individual targets. Handlers should honour the context: when it expires, the request fails with HTTP status 504.
+
 # Annotations
 
 The /annotations endpoint returns Annotations:
 
-	func (h *handler) Annotations(_ simplejson.QueryRequest) (annotations []simplejson.Annotation, err error) {
+	func (h *handler) Annotations(_ simplejson.AnnotationRequest) (annotations []simplejson.Annotation, err error) {
 		annotations = []simplejson.Annotation{
 			{
 				Time:  time.Now().Add(-5 * time.Minute),
@@ -96,8 +167,43 @@ The /annotations endpoint returns Annotations:
 		return
 	}
 
+A handler that needs the request's context (e.g. to find its target or parameters, or to honour a timeout) implements
+the AnnotationsContext endpoint instead:
+
+	func (h *handler) Endpoints() simplejson.Endpoints {
+		return simplejson.Endpoints{AnnotationsContext: h.annotations}
+	}
+
+	func (h *handler) annotations(ctx context.Context, req simplejson.AnnotationRequest) ([]simplejson.Annotation, error) {
+		// ...
+	}
+
+The annotation's query selects the target whose annotations are returned, optionally followed by key=value parameters,
+e.g. "deployments env=prod". The target can also match a pattern registered with RegisterPattern. The handler finds the
+parameters (and the values captured by the pattern) through TargetParams. If the query doesn't start with a registered
+target (e.g. it is empty, or free text), the annotations of all handlers are returned. A pattern handler is then called for
+each target returned by its Targets endpoint.
+
+With the WithAnnotationFilter option, the server only returns the annotations that overlap with the request's time range,
+and/or the annotations that have all tags listed in the query's "tags" parameter (e.g. "tags=deploy,prod"). A handler can
+then simply return all its annotations.
+
+The annotations package provides a ready-made handler that keeps annotations in a file, with an HTTP API to add and remove
+them (e.g. to record deployments from a CI pipeline). Use the WithHTTPHandler option to serve that API from the Server, so its
+requests are logged and recorded in the Server's HTTP metrics.
+
+By default, if a handler's Annotations function returns an error, the error is logged and the annotations of the other
+handlers are returned. If all handlers fail, the response is empty. Use the WithAnnotationErrorPolicy option to fail the
+request instead, or to only fail it when all handlers fail.
+
 NOTE: this is only called when using the SimpleJSON datasource. simPod/GrafanaJsonDatasource does not use the /annotations endpoint.
 Instead, it will call a regular /query and allows to configure its response as annotations instead.
+To serve the same annotations to that datasource, a handler's Query function returns them as an AnnotationsResponse:
+
+	func (h *handler) Query(ctx context.Context, req simplejson.QueryRequest) (simplejson.Response, error) {
+		annotations, err := h.Annotations(simplejson.AnnotationRequest{Args: req.Args})
+		return simplejson.AnnotationsResponse(annotations), err
+	}
 
 # Tags
 
@@ -118,13 +224,38 @@ The following sets up a key & key value handler:
 	}
 
 When the dashboard performs a query with a tag selected, that tag & value will be added in the request's AdHocFilters.
+A handler can use AdHocFilters.Match to determine if a set of labels matches the selected filters:
+
+	if ok, err := req.AdHocFilters.Match(map[string]string{"some-key": "A"}); err == nil && ok {
+		// add the data to the response
+	}
+
+To match many sets of labels (e.g. one per row of a table), compile the filters once with AdHocFilters.Compile.
+
+# simPod JSON datasource
+
+The server also implements the endpoints of simPod's [GrafanaJsonDatasource]:
+
+  - MetricPayloads()       implements the /metrics endpoint: returns the payload options of a target, shown in the query editor
+  - MetricPayloadOptions() implements the /metric-payload-options endpoint: returns the options of a "select" payload
+  - Variable()             implements the /variable endpoint: returns the values of a template variable
+
+The payload selected in the query editor is sent with each target of a query request and can be found in the Target's Payload field.
+Use Target.DecodePayload to decode it into a handler-specific structure:
+
+	var payload struct {
+		Host string `json:"host"`
+	}
+	err := target.DecodePayload(&payload)
 
 # Metrics
 
-When provided with the WithQueryMetrics option, simplejson exports two Prometheus metrics for performance analytics:
+When provided with the WithQueryMetrics option, simplejson exports the following Prometheus metrics for performance analytics:
 
-	simplejson_query_duration_seconds: duration of query requests by target, in seconds
-	simplejson_query_failed_count:     number of failed query requests
+	simplejson_query_duration_seconds:   duration of query requests by target, in seconds
+	simplejson_query_failed_count:       number of failed query requests, by target
+	simplejson_query_timeout_count:      number of query requests that timed out, by target
+	simplejson_annotations_failed_count: number of failed annotations requests, by target and reason
 
 The underlying http router uses [PrometheusMetrics], which exports its own set of metrics. See WithHTTPMetrics for details.
 
@@ -132,6 +263,7 @@ The underlying http router uses [PrometheusMetrics], which exports its own set o
 
 For information on query arguments and tags, refer to the documentation for those data structures.
 
+[GrafanaJsonDatasource]: https://github.com/simPod/GrafanaJsonDatasource
 [PrometheusMetrics]: https://pkg.go.dev/github.com/clambin/go-common/httpserver/middleware
 */
 package simplejson
@@ -0,0 +1,254 @@
+package simplejson
+
+import (
+	"math"
+	"time"
+)
+
+// DownsampleAlgorithm selects how TimeSeriesResponse.Downsample reduces the number of data points in a timeseries.
+type DownsampleAlgorithm int
+
+const (
+	// DownsampleLTTB uses the Largest-Triangle-Three-Buckets algorithm, which preserves the visual shape of the timeseries.
+	DownsampleLTTB DownsampleAlgorithm = iota
+	// DownsampleMinMax keeps the minimum and maximum value of each bucket, which preserves peaks in the timeseries.
+	DownsampleMinMax
+	// DownsampleAverage replaces each bucket by the average of its values.
+	DownsampleAverage
+)
+
+// Downsample returns a TimeSeriesResponse with at most maxDataPoints data points, using the specified algorithm. The data points
+// are expected to be sorted by timestamp. If the response already has no more than maxDataPoints, or maxDataPoints is zero,
+// Downsample returns the response unchanged.
+//
+// NaN and infinite values are ignored. A bucket that only holds such values results in a NaN value, which is sent as null.
+//
+// DownsampleLTTB needs at least 3 data points and DownsampleMinMax at least 2. With a lower maxDataPoints, both algorithms
+// return the first and the last data point (or only the first data point, if maxDataPoints is 1).
+func (r TimeSeriesResponse) Downsample(maxDataPoints int, algorithm DownsampleAlgorithm) TimeSeriesResponse {
+	if maxDataPoints <= 0 || len(r.DataPoints) <= maxDataPoints {
+		return r
+	}
+
+	input := make([]point, len(r.DataPoints))
+	for index, d := range r.DataPoints {
+		input[index] = point{timestamp: d.Timestamp, value: d.Value, valid: isFinite(d.Value)}
+	}
+	output := downsample(input, maxDataPoints, algorithm)
+	r.DataPoints = make([]DataPoint, len(output))
+	for index, p := range output {
+		r.DataPoints[index] = DataPoint{Timestamp: p.timestamp, Value: p.value}
+		if !p.valid {
+			r.DataPoints[index].Value = math.NaN()
+		}
+	}
+	return r
+}
+
+// Downsample returns a NullableTimeSeriesResponse with at most maxDataPoints data points, using the specified algorithm.
+// It works as TimeSeriesResponse.Downsample, but also ignores missing values. A bucket that only holds missing (or NaN
+// or infinite) values results in a missing value, so gaps that span a whole bucket are preserved.
+func (r NullableTimeSeriesResponse) Downsample(maxDataPoints int, algorithm DownsampleAlgorithm) NullableTimeSeriesResponse {
+	if maxDataPoints <= 0 || len(r.DataPoints) <= maxDataPoints {
+		return r
+	}
+
+	input := make([]point, len(r.DataPoints))
+	for index, d := range r.DataPoints {
+		input[index] = point{timestamp: d.Timestamp, valid: d.Value != nil && isFinite(*d.Value)}
+		if input[index].valid {
+			input[index].value = *d.Value
+		}
+	}
+	output := downsample(input, maxDataPoints, algorithm)
+	r.DataPoints = make([]NullableDataPoint, len(output))
+	for index, p := range output {
+		r.DataPoints[index] = NullableDataPoint{Timestamp: p.timestamp}
+		if p.valid {
+			value := p.value
+			r.DataPoints[index].Value = &value
+		}
+	}
+	return r
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// point is a data point of a timeseries. valid is false for a missing, NaN or infinite value.
+type point struct {
+	timestamp time.Time
+	value     float64
+	valid     bool
+}
+
+func downsample(dataPoints []point, maxDataPoints int, algorithm DownsampleAlgorithm) []point {
+	switch algorithm {
+	case DownsampleLTTB:
+		if maxDataPoints < 3 {
+			return firstAndLast(dataPoints, maxDataPoints)
+		}
+		return downsampleLTTB(dataPoints, maxDataPoints)
+	case DownsampleMinMax:
+		if maxDataPoints < 2 {
+			return firstAndLast(dataPoints, maxDataPoints)
+		}
+		return downsampleMinMax(dataPoints, maxDataPoints)
+	default:
+		return downsampleAverage(dataPoints, maxDataPoints)
+	}
+}
+
+func firstAndLast(dataPoints []point, maxDataPoints int) []point {
+	if maxDataPoints < 2 {
+		return []point{dataPoints[0]}
+	}
+	return []point{dataPoints[0], dataPoints[len(dataPoints)-1]}
+}
+
+// bucket returns the start and end index of bucket i, when dividing count data points in n buckets
+func bucket(i, n, count int) (int, int) {
+	return i * count / n, (i + 1) * count / n
+}
+
+func downsampleAverage(dataPoints []point, maxDataPoints int) []point {
+	output := make([]point, 0, maxDataPoints)
+	for i := 0; i < maxDataPoints; i++ {
+		start, end := bucket(i, maxDataPoints, len(dataPoints))
+		var total float64
+		var count int
+		for _, d := range dataPoints[start:end] {
+			if d.valid {
+				total += d.value
+				count++
+			}
+		}
+		p := point{timestamp: dataPoints[start].timestamp}
+		if count > 0 {
+			p.value, p.valid = total/float64(count), true
+		}
+		output = append(output, p)
+	}
+	return output
+}
+
+func downsampleMinMax(dataPoints []point, maxDataPoints int) []point {
+	buckets := maxDataPoints / 2
+	output := make([]point, 0, 2*buckets)
+	for i := 0; i < buckets; i++ {
+		start, end := bucket(i, buckets, len(dataPoints))
+		low, high := -1, -1
+		for j := start; j < end; j++ {
+			if !dataPoints[j].valid {
+				continue
+			}
+			if low == -1 || dataPoints[j].value < dataPoints[low].value {
+				low = j
+			}
+			if high == -1 || dataPoints[j].value > dataPoints[high].value {
+				high = j
+			}
+		}
+		switch {
+		case low == -1:
+			output = append(output, dataPoints[start])
+		case low < high:
+			output = append(output, dataPoints[low], dataPoints[high])
+		case low > high:
+			output = append(output, dataPoints[high], dataPoints[low])
+		default:
+			output = append(output, dataPoints[low])
+		}
+	}
+	return output
+}
+
+// downsampleLTTB implements the Largest-Triangle-Three-Buckets algorithm, as described in Sveinn Steinarsson's thesis
+// "Downsampling Time Series for Visual Representation". The first and last data points are always kept.
+func downsampleLTTB(dataPoints []point, maxDataPoints int) []point {
+	output := make([]point, 0, maxDataPoints)
+	output = append(output, dataPoints[0])
+
+	// divide all data points, except the first and last one, in buckets
+	count := len(dataPoints) - 2
+	buckets := maxDataPoints - 2
+	previous := -1
+	if dataPoints[0].valid {
+		previous = 0
+	}
+	for i := 0; i < buckets; i++ {
+		start, end := bucket(i, buckets, count)
+		start, end = start+1, end+1
+
+		// average of the next bucket. for the last bucket, this is the last data point
+		nextStart, nextEnd := len(dataPoints)-1, len(dataPoints)
+		if i < buckets-1 {
+			nextStart, nextEnd = bucket(i+1, buckets, count)
+			nextStart, nextEnd = nextStart+1, nextEnd+1
+		}
+		var avgX, avgY float64
+		var n int
+		for _, d := range dataPoints[nextStart:nextEnd] {
+			if d.valid {
+				avgX += float64(d.timestamp.UnixMilli())
+				avgY += d.value
+				n++
+			}
+		}
+		if n > 0 {
+			avgX /= float64(n)
+			avgY /= float64(n)
+		} else {
+			avgX = float64(dataPoints[nextStart].timestamp.UnixMilli())
+		}
+
+		// select the data point that forms the largest triangle with the previous selected data point and the next bucket's average.
+		// without a previous data point, select the data point furthest from the next bucket's average.
+		prevX, prevY := float64(dataPoints[start].timestamp.UnixMilli()), avgY
+		if previous != -1 {
+			prevX, prevY = float64(dataPoints[previous].timestamp.UnixMilli()), dataPoints[previous].value
+		}
+		selected, maxArea := start, -1.0
+		for j := start; j < end; j++ {
+			if !dataPoints[j].valid {
+				continue
+			}
+			area := math.Abs((prevX-avgX)*(dataPoints[j].value-prevY) - (prevX-float64(dataPoints[j].timestamp.UnixMilli()))*(avgY-prevY))
+			if area > maxArea {
+				selected, maxArea = j, area
+			}
+		}
+		output = append(output, dataPoints[selected])
+		if dataPoints[selected].valid {
+			previous = selected
+		}
+	}
+
+	return append(output, dataPoints[len(dataPoints)-1])
+}
+
+// downsample reduces the number of data points of a timeseries response to the request's MaxDataPoints, if the Server
+// is configured to downsample responses.
+func (s *Server) downsample(response Response, maxDataPoints uint64) Response {
+	if s.downsampling == nil || maxDataPoints == 0 {
+		return response
+	}
+	switch r := response.(type) {
+	case TimeSeriesResponse:
+		return r.Downsample(int(maxDataPoints), *s.downsampling)
+	case *TimeSeriesResponse:
+		if r != nil {
+			downsampled := r.Downsample(int(maxDataPoints), *s.downsampling)
+			return &downsampled
+		}
+	case NullableTimeSeriesResponse:
+		return r.Downsample(int(maxDataPoints), *s.downsampling)
+	case *NullableTimeSeriesResponse:
+		if r != nil {
+			downsampled := r.Downsample(int(maxDataPoints), *s.downsampling)
+			return &downsampled
+		}
+	}
+	return response
+}
@@ -0,0 +1,184 @@
+package simplejson_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/clambin/simplejson/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeSeriesResponse_Downsample(t *testing.T) {
+	input := makeTimeSeriesResponse(0, 1, 5, 2, 3, 9, 4, 4, 0, 2)
+
+	testCases := []struct {
+		name          string
+		algorithm     simplejson.DownsampleAlgorithm
+		maxDataPoints int
+		want          simplejson.TimeSeriesResponse
+	}{
+		{name: "no maximum", algorithm: simplejson.DownsampleLTTB, maxDataPoints: 0, want: input},
+		{name: "below maximum", algorithm: simplejson.DownsampleLTTB, maxDataPoints: 10, want: input},
+		{name: "average", algorithm: simplejson.DownsampleAverage, maxDataPoints: 5, want: makeSparseTimeSeriesResponse(map[int]float64{0: 0.5, 2: 3.5, 4: 6, 6: 4, 8: 1})},
+		{name: "average - uneven buckets", algorithm: simplejson.DownsampleAverage, maxDataPoints: 3, want: makeSparseTimeSeriesResponse(map[int]float64{0: 2, 3: 14.0 / 3, 6: 2.5})},
+		{name: "minmax", algorithm: simplejson.DownsampleMinMax, maxDataPoints: 4, want: makeSparseTimeSeriesResponse(map[int]float64{0: 0, 2: 5, 5: 9, 8: 0})},
+		{name: "minmax - too few data points", algorithm: simplejson.DownsampleMinMax, maxDataPoints: 1, want: makeSparseTimeSeriesResponse(map[int]float64{0: 0})},
+		{name: "lttb", algorithm: simplejson.DownsampleLTTB, maxDataPoints: 5, want: makeSparseTimeSeriesResponse(map[int]float64{0: 0, 2: 5, 5: 9, 8: 0, 9: 2})},
+		{name: "lttb - too few data points", algorithm: simplejson.DownsampleLTTB, maxDataPoints: 2, want: makeSparseTimeSeriesResponse(map[int]float64{0: 0, 9: 2})},
+		{name: "lttb - single data point", algorithm: simplejson.DownsampleLTTB, maxDataPoints: 1, want: makeSparseTimeSeriesResponse(map[int]float64{0: 0})},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			output := input.Downsample(tt.maxDataPoints, tt.algorithm)
+			assert.Equal(t, tt.want, output)
+			assert.LessOrEqual(t, len(output.DataPoints), len(input.DataPoints))
+		})
+	}
+}
+
+func TestTimeSeriesResponse_Downsample_NaN(t *testing.T) {
+	input := makeTimeSeriesResponse(math.NaN(), 5, 100, 3, 1, 2, math.Inf(1), 4, 0, 2)
+
+	testCases := []struct {
+		name          string
+		algorithm     simplejson.DownsampleAlgorithm
+		maxDataPoints int
+		want          simplejson.TimeSeriesResponse
+	}{
+		{name: "average", algorithm: simplejson.DownsampleAverage, maxDataPoints: 5, want: makeSparseTimeSeriesResponse(map[int]float64{0: 5, 2: 51.5, 4: 1.5, 6: 4, 8: 1})},
+		{name: "minmax", algorithm: simplejson.DownsampleMinMax, maxDataPoints: 4, want: makeSparseTimeSeriesResponse(map[int]float64{2: 100, 4: 1, 7: 4, 8: 0})},
+		{name: "lttb", algorithm: simplejson.DownsampleLTTB, maxDataPoints: 4, want: makeSparseTimeSeriesResponse(map[int]float64{0: math.NaN(), 2: 100, 5: 2, 9: 2})},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			// compare the JSON output, as NaN != NaN
+			want, err := tt.want.MarshalJSON()
+			require.NoError(t, err)
+			output, err := input.Downsample(tt.maxDataPoints, tt.algorithm).MarshalJSON()
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(output))
+		})
+	}
+}
+
+func TestNullableTimeSeriesResponse_Downsample(t *testing.T) {
+	input := makeNullableTimeSeriesResponse(pointer(1.0), nil, nil, nil, pointer(5.0), pointer(6.0), nil, pointer(2.0))
+
+	testCases := []struct {
+		name      string
+		algorithm simplejson.DownsampleAlgorithm
+		want      simplejson.NullableTimeSeriesResponse
+	}{
+		{name: "average", algorithm: simplejson.DownsampleAverage, want: makeSparseNullableTimeSeriesResponse(map[int]*float64{0: pointer(1.0), 2: nil, 4: pointer(5.5), 6: pointer(2.0)})},
+		{name: "minmax", algorithm: simplejson.DownsampleMinMax, want: makeSparseNullableTimeSeriesResponse(map[int]*float64{0: pointer(1.0), 5: pointer(6.0), 7: pointer(2.0)})},
+		{name: "lttb", algorithm: simplejson.DownsampleLTTB, want: makeSparseNullableTimeSeriesResponse(map[int]*float64{0: pointer(1.0), 1: nil, 5: pointer(6.0), 7: pointer(2.0)})},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, input.Downsample(4, tt.algorithm))
+		})
+	}
+
+	// NaN values are ignored, as missing values
+	input = makeNullableTimeSeriesResponse(pointer(1.0), pointer(math.NaN()), pointer(7.0), pointer(3.0))
+	assert.Equal(t,
+		makeSparseNullableTimeSeriesResponse(map[int]*float64{0: pointer(1.0), 2: pointer(5.0)}),
+		input.Downsample(2, simplejson.DownsampleAverage),
+	)
+}
+
+func TestWithDownsampling(t *testing.T) {
+	response := buildTimeSeriesResponse(1000)
+	h := queryHandler(func(_ context.Context, _ simplejson.QueryRequest) (simplejson.Response, error) {
+		return &response, nil
+	})
+	nullable := simplejson.NullableTimeSeriesResponse{Target: "B", DataPoints: make([]simplejson.NullableDataPoint, 1000)}
+	for index := range nullable.DataPoints {
+		nullable.DataPoints[index] = simplejson.NullableDataPoint{Timestamp: response.DataPoints[index].Timestamp, Value: pointer(float64(index))}
+	}
+	n := queryHandler(func(_ context.Context, _ simplejson.QueryRequest) (simplejson.Response, error) {
+		return nullable, nil
+	})
+
+	testCases := []struct {
+		name    string
+		options []simplejson.Option
+		request string
+		want    int
+	}{
+		{name: "disabled", request: `{ "maxDataPoints": 100, "targets": [ { "target": "A" }, { "target": "B" } ] }`, want: 1000},
+		{name: "enabled", options: []simplejson.Option{simplejson.WithDownsampling{}}, request: `{ "maxDataPoints": 100, "targets": [ { "target": "A" }, { "target": "B" } ] }`, want: 100},
+		{name: "no maxDataPoints", options: []simplejson.Option{simplejson.WithDownsampling{}}, request: `{ "targets": [ { "target": "A" }, { "target": "B" } ] }`, want: 1000},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := simplejson.New(map[string]simplejson.Handler{"A": h, "B": n}, tt.options...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(tt.request))
+			r.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var responses []struct {
+				DataPoints [][2]float64 `json:"datapoints"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responses))
+			require.Len(t, responses, 2)
+			for _, response := range responses {
+				assert.Len(t, response.DataPoints, tt.want)
+			}
+		})
+	}
+	// the handler's response is not modified
+	assert.Len(t, response.DataPoints, 1000)
+}
+
+func makeTimeSeriesResponse(values ...float64) simplejson.TimeSeriesResponse {
+	timestamp := time.Date(2022, time.November, 27, 0, 0, 0, 0, time.UTC)
+	dataPoints := make([]simplejson.DataPoint, len(values))
+	for i, value := range values {
+		dataPoints[i] = simplejson.DataPoint{Timestamp: timestamp.Add(time.Duration(i) * time.Minute), Value: value}
+	}
+	return simplejson.TimeSeriesResponse{Target: "foo", DataPoints: dataPoints}
+}
+
+func makeSparseTimeSeriesResponse(values map[int]float64) simplejson.TimeSeriesResponse {
+	timestamp := time.Date(2022, time.November, 27, 0, 0, 0, 0, time.UTC)
+	var dataPoints []simplejson.DataPoint
+	for i := 0; i < 10; i++ {
+		if value, ok := values[i]; ok {
+			dataPoints = append(dataPoints, simplejson.DataPoint{Timestamp: timestamp.Add(time.Duration(i) * time.Minute), Value: value})
+		}
+	}
+	return simplejson.TimeSeriesResponse{Target: "foo", DataPoints: dataPoints}
+}
+
+func makeNullableTimeSeriesResponse(values ...*float64) simplejson.NullableTimeSeriesResponse {
+	timestamp := time.Date(2022, time.November, 27, 0, 0, 0, 0, time.UTC)
+	dataPoints := make([]simplejson.NullableDataPoint, len(values))
+	for i, value := range values {
+		dataPoints[i] = simplejson.NullableDataPoint{Timestamp: timestamp.Add(time.Duration(i) * time.Minute), Value: value}
+	}
+	return simplejson.NullableTimeSeriesResponse{Target: "foo", DataPoints: dataPoints}
+}
+
+func makeSparseNullableTimeSeriesResponse(values map[int]*float64) simplejson.NullableTimeSeriesResponse {
+	timestamp := time.Date(2022, time.November, 27, 0, 0, 0, 0, time.UTC)
+	var dataPoints []simplejson.NullableDataPoint
+	for i := 0; i < 10; i++ {
+		if value, ok := values[i]; ok {
+			dataPoints = append(dataPoints, simplejson.NullableDataPoint{Timestamp: timestamp.Add(time.Duration(i) * time.Minute), Value: value})
+		}
+	}
+	return simplejson.NullableTimeSeriesResponse{Target: "foo", DataPoints: dataPoints}
+}
@@ -1,22 +1,32 @@
 package simplejson
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-http-utils/headers"
 	"net/http"
-	"sort"
 )
 
-func (s *Server) Search(w http.ResponseWriter, _ *http.Request) {
-	var targets []string
-	for target := range s.Handlers {
-		targets = append(targets, target)
+func (s *Server) Search(w http.ResponseWriter, req *http.Request) {
+	var request searchRequest
+	if req.ContentLength > 0 {
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			http.Error(w, "failed to parse request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := s.search(req.Context(), request.Target)
+	if err != nil {
+		http.Error(w, "failed to process request: "+err.Error(), errorStatus(err))
+		return
 	}
-	sort.Strings(targets)
 
 	//w.WriteHeader(http.StatusOK)
 	w.Header().Set(headers.ContentType, "application/json")
-	output, _ := json.Marshal(targets)
+	output, _ := json.Marshal(results)
 	_, _ = w.Write(output)
 }
 
@@ -38,13 +48,9 @@ func (s *Server) Annotations(w http.ResponseWriter, req *http.Request) {
 
 	var request AnnotationRequest
 	handleEndpoint(w, req, &request, func() ([]json.Marshaler, error) {
-		var annotations []Annotation
-		for _, h := range s.Handlers {
-			if h.Endpoints().Annotations != nil {
-				if newAnnotations, err := h.Endpoints().Annotations(request); err == nil {
-					annotations = append(annotations, newAnnotations...)
-				}
-			}
+		annotations, err := s.annotations(req.Context(), request)
+		if err != nil {
+			return nil, err
 		}
 
 		var response []json.Marshaler
@@ -56,30 +62,152 @@ func (s *Server) Annotations(w http.ResponseWriter, req *http.Request) {
 	})
 }
 
+// annotations returns the annotations of the handlers selected by the annotation query. Handler errors are handled
+// according to the Server's AnnotationErrorPolicy.
+func (s *Server) annotations(ctx context.Context, request AnnotationRequest) ([]Annotation, error) {
+	targets, err := s.getAnnotationTargets(ctx, request.Annotation.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	var annotations []Annotation
+	var failed int
+	for _, t := range targets {
+		newAnnotations, err := s.handlerAnnotations(withTargetParams(withTarget(ctx, Target{Name: t.target}), t.params), t.target, t.annotations, request)
+		if err != nil {
+			failed++
+			if s.queryMetrics != nil {
+				s.queryMetrics.observeAnnotationsError(t.target, err)
+			}
+			if s.annotationErrorPolicy == AnnotationErrorFail {
+				return nil, fmt.Errorf("annotations failed for target '%s': %w", t.target, err)
+			}
+			s.logger.Warn("annotations failed", "target", t.target, "err", err)
+			continue
+		}
+		if s.filterAnnotationsRange || s.filterAnnotationsTags {
+			newAnnotations = s.filterAnnotations(newAnnotations, request.Range, t.params)
+		}
+		annotations = append(annotations, newAnnotations...)
+	}
+	if s.annotationErrorPolicy == AnnotationErrorPartial && failed > 0 && failed == len(targets) {
+		return nil, errors.New("annotations failed for all targets")
+	}
+	return annotations, nil
+}
+
+// filterAnnotations applies the Server's annotation filters to a handler's annotations.
+func (s *Server) filterAnnotations(annotations []Annotation, r Range, params map[string]string) []Annotation {
+	if !s.filterAnnotationsRange {
+		r = Range{}
+	}
+	var tags []string
+	if s.filterAnnotationsTags {
+		tags = annotationTags(params)
+	}
+	return filterAnnotations(annotations, r, tags)
+}
+
+// annotationTarget is a target whose annotations are returned for an annotation request.
+type annotationTarget struct {
+	target      string
+	annotations AnnotationsContextFunc
+	params      map[string]string
+}
+
+// getAnnotationTargets returns the targets selected by the annotation query. If the query doesn't start with a registered
+// target (e.g. it is empty, or it is free text), all handlers that implement the Annotations endpoint are selected.
+// Pattern handlers are then called for each target returned by their Targets endpoint.
+func (s *Server) getAnnotationTargets(ctx context.Context, query string) ([]annotationTarget, error) {
+	target, queryParams := parseAnnotationQuery(query)
+	if target != "" {
+		if handler, params, ok := s.getHandler(target); ok {
+			f := handler.Endpoints().annotations()
+			if f == nil {
+				return nil, fmt.Errorf("annotations not implemented for target '%s'", target)
+			}
+			return []annotationTarget{{target: target, annotations: f, params: mergeParams(params, queryParams)}}, nil
+		}
+	}
+
+	var targets []annotationTarget
+	for _, h := range s.getHandlers() {
+		f := h.handler.Endpoints().annotations()
+		if f == nil {
+			continue
+		}
+		if h.pattern == nil {
+			targets = append(targets, annotationTarget{target: h.target, annotations: f, params: queryParams})
+			continue
+		}
+		for _, t := range h.targets(ctx) {
+			if params, ok := h.pattern.match(t); ok {
+				targets = append(targets, annotationTarget{target: t, annotations: f, params: mergeParams(params, queryParams)})
+			}
+		}
+	}
+	return targets, nil
+}
+
+// mergeParams adds the query's parameters to the parameters captured by a pattern.
+func mergeParams(params, queryParams map[string]string) map[string]string {
+	if len(queryParams) > 0 && params == nil {
+		params = make(map[string]string, len(queryParams))
+	}
+	for key, value := range queryParams {
+		params[key] = value
+	}
+	return params
+}
+
+func (s *Server) handlerAnnotations(ctx context.Context, target string, f AnnotationsContextFunc, request AnnotationRequest) ([]Annotation, error) {
+	ctx, cancel := s.withTimeout(ctx, target)
+	defer cancel()
+	annotations, err := f(ctx, request)
+	if err != nil {
+		return nil, timeoutError(ctx, target, err)
+	}
+	return annotations, nil
+}
+
 func (s *Server) TagKeys(w http.ResponseWriter, req *http.Request) {
 	handleEndpoint(w, req, nil, func() (keys []json.Marshaler, _ error) {
-		for _, handler := range s.Handlers {
-			if handler.Endpoints().TagKeys != nil {
-				for _, newKey := range handler.Endpoints().TagKeys(req.Context()) {
-					keys = append(keys, &tagKey{Type: "string", Text: newKey})
-				}
+		for _, h := range s.getHandlers() {
+			if h.handler.Endpoints().TagKeys == nil {
+				continue
+			}
+			newKeys, err := s.tagKeys(req.Context(), h.target, h.handler.Endpoints().TagKeys)
+			if err != nil {
+				return nil, err
+			}
+			for _, newKey := range newKeys {
+				keys = append(keys, &tagKey{Type: "string", Text: newKey})
 			}
 		}
 		return keys, nil
 	})
 }
 
+func (s *Server) tagKeys(ctx context.Context, target string, f TagKeysFunc) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx, target)
+	defer cancel()
+	keys := f(ctx)
+	if ctx.Err() != nil {
+		return nil, timeoutError(ctx, target, ctx.Err())
+	}
+	return keys, nil
+}
+
 func (s *Server) TagValues(w http.ResponseWriter, req *http.Request) {
 	var key valueKey
 	handleEndpoint(w, req, &key, func() ([]json.Marshaler, error) {
 		var response []json.Marshaler
-		for _, handler := range s.Handlers {
-			if handler.Endpoints().TagValues == nil {
+		for _, h := range s.getHandlers() {
+			if h.handler.Endpoints().TagValues == nil {
 				continue
 			}
-			values, err := handler.Endpoints().TagValues(req.Context(), key.Key)
+			values, err := s.tagValues(req.Context(), h.target, h.handler.Endpoints().TagValues, key.Key)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return nil, err
 			}
 
@@ -91,6 +219,16 @@ func (s *Server) TagValues(w http.ResponseWriter, req *http.Request) {
 	})
 }
 
+func (s *Server) tagValues(ctx context.Context, target string, f TagValuesFunc, key string) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx, target)
+	defer cancel()
+	values, err := f(ctx, key)
+	if err != nil {
+		err = timeoutError(ctx, target, err)
+	}
+	return values, err
+}
+
 type tagKey struct {
 	Type string `json:"type"`
 	Text string `json:"text"`
@@ -102,6 +240,19 @@ func (t tagKey) MarshalJSON() (b []byte, err error) {
 	return json.Marshal(t2)
 }
 
+type searchRequest struct {
+	Target string `json:"target"`
+}
+
+func (r *searchRequest) UnmarshalJSON(b []byte) (err error) {
+	type searchRequest2 searchRequest
+	var c searchRequest2
+	if err = json.Unmarshal(b, &c); err == nil {
+		*r = searchRequest(c)
+	}
+	return err
+}
+
 type valueKey struct {
 	Key string `json:"key"`
 }
@@ -138,7 +289,7 @@ func handleEndpoint(w http.ResponseWriter, req *http.Request, request json.Unmar
 
 	response, err := processor()
 	if err != nil {
-		http.Error(w, "failed to process request: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "failed to process request: "+err.Error(), errorStatus(err))
 		return
 	}
 
@@ -150,3 +301,11 @@ func handleEndpoint(w http.ResponseWriter, req *http.Request, request json.Unmar
 		return
 	}
 }
+
+// errorStatus returns the http status code for an error returned by a handler.
+func errorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
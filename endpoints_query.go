@@ -5,46 +5,130 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
+	"sync"
 )
 
+// handleQuery processes all targets of a query request in parallel, up to the Server's maximum number of concurrent queries.
+// Responses are returned in the order of the request's targets. If one target fails, the remaining targets are cancelled,
+// unless the Server is configured to return partial results. In that case, the failed target is reported as a queryError.
 func (s *Server) handleQuery(ctx context.Context, request QueryRequest) ([]json.Marshaler, error) {
-	responses := make([]json.Marshaler, 0, len(request.Targets))
-	for _, target := range request.Targets {
-		var timer *prometheus.Timer
-		if s.queryMetrics != nil {
-			timer = prometheus.NewTimer(s.queryMetrics.duration.WithLabelValues(target.Name, target.Type))
-		}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		response, err := s.handleQueryRequest(ctx, target, request)
+	limit := s.maxConcurrentQueries
+	if limit <= 0 || limit > len(request.Targets) {
+		limit = len(request.Targets)
+	}
+	sem := make(chan struct{}, limit)
 
-		if timer != nil {
-			timer.ObserveDuration()
+	responses := make([]json.Marshaler, len(request.Targets))
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+loop:
+	for index := range request.Targets {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+			break loop
 		}
-		if err != nil {
-			if s.queryMetrics != nil {
-				s.queryMetrics.errors.WithLabelValues(target.Name, target.Type).Add(1.0)
+
+		wg.Add(1)
+		go func(index int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			target := request.Targets[index]
+			response, err := s.handleQueryTarget(ctx, target, request)
+			if err != nil {
+				if !s.partialResults {
+					fail(err)
+					return
+				}
+				response = queryError{Target: target.Name, RefID: target.RefID, Error: err.Error()}
 			}
-			return nil, err
-		}
-		responses = append(responses, response)
+			responses[index] = response
+		}(index)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
 	}
 	return responses, nil
 }
 
+func (s *Server) handleQueryTarget(ctx context.Context, target Target, request QueryRequest) (Response, error) {
+	var timer *prometheus.Timer
+	if s.queryMetrics != nil {
+		timer = prometheus.NewTimer(s.queryMetrics.duration.WithLabelValues(target.Name, target.Type))
+	}
+
+	response, err := s.handleQueryRequest(ctx, target, request)
+
+	if timer != nil {
+		timer.ObserveDuration()
+	}
+	if err != nil && s.queryMetrics != nil {
+		s.queryMetrics.observeError(target, err)
+	}
+	return response, err
+}
+
+// queryError reports a failed target when the Server returns partial results. It is marshalled as an empty timeseries,
+// so Grafana still renders the other targets, with the error message keyed by the target's refId.
+type queryError struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId,omitempty"`
+	Error  string `json:"error"`
+}
+
+// MarshalJSON converts a queryError to JSON.
+func (e queryError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Target     string      `json:"target"`
+		RefID      string      `json:"refId,omitempty"`
+		DataPoints []DataPoint `json:"datapoints"`
+		Error      string      `json:"error"`
+	}{
+		Target:     e.Target,
+		RefID:      e.RefID,
+		DataPoints: []DataPoint{},
+		Error:      e.Error,
+	})
+}
+
 type Response interface {
 	MarshalJSON() ([]byte, error)
 }
 
 func (s *Server) handleQueryRequest(ctx context.Context, target Target, request QueryRequest) (Response, error) {
-	handler, ok := s.Handlers[target.Name]
+	handler, params, ok := s.getHandler(target.Name)
 	if !ok {
-		return nil, fmt.Errorf("no handler found for target '%s'", target)
+		return nil, fmt.Errorf("no handler found for target '%s'", target.Name)
 	}
 
 	q := handler.Endpoints().Query
 	if q == nil {
-		return nil, fmt.Errorf("query not implemented for target '%s'", target)
+		return nil, fmt.Errorf("query not implemented for target '%s'", target.Name)
 	}
 
-	return q(ctx, request)
+	ctx, cancel := s.withTimeout(withTargetParams(withTarget(ctx, target), params), target.Name)
+	defer cancel()
+	response, err := q(ctx, request)
+	if err != nil {
+		return nil, timeoutError(ctx, target.Name, err)
+	}
+	return s.downsample(s.applyNaNPolicy(response), request.MaxDataPoints), nil
 }
@@ -2,6 +2,12 @@ package simplejson_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/clambin/simplejson/v6"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io"
@@ -10,7 +16,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestServer_Query(t *testing.T) {
@@ -98,6 +106,169 @@ func TestServer_Query(t *testing.T) {
 	}
 }
 
+func TestServer_Query_Concurrent(t *testing.T) {
+	var current, highest atomic.Int32
+	h := queryHandler(func(_ context.Context, req simplejson.QueryRequest) (simplejson.Response, error) {
+		n := current.Add(1)
+		defer current.Add(-1)
+		for {
+			old := highest.Load()
+			if n <= old || highest.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return simplejson.TimeSeriesResponse{Target: "A"}, nil
+	})
+	r := simplejson.New(map[string]simplejson.Handler{"A": h}, simplejson.WithMaxConcurrentQueries{Max: 2})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [
+		{ "target": "A" }, { "target": "A" }, { "target": "A" }, { "target": "A" }, { "target": "A" }, { "target": "A" }
+	] }`))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[{"target":"A","datapoints":null},{"target":"A","datapoints":null},{"target":"A","datapoints":null},{"target":"A","datapoints":null},{"target":"A","datapoints":null},{"target":"A","datapoints":null}]
+`, w.Body.String())
+	assert.Equal(t, int32(2), highest.Load())
+}
+
+func TestServer_Query_Order(t *testing.T) {
+	h := make(map[string]simplejson.Handler)
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("%d", i)
+		delay := time.Duration(5-i) * 10 * time.Millisecond
+		h[name] = queryHandler(func(_ context.Context, _ simplejson.QueryRequest) (simplejson.Response, error) {
+			time.Sleep(delay)
+			return simplejson.TimeSeriesResponse{Target: name}, nil
+		})
+	}
+	r := simplejson.New(h)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [
+		{ "target": "0" }, { "target": "1" }, { "target": "2" }, { "target": "3" }, { "target": "4" }
+	] }`))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[{"target":"0","datapoints":null},{"target":"1","datapoints":null},{"target":"2","datapoints":null},{"target":"3","datapoints":null},{"target":"4","datapoints":null}]
+`, w.Body.String())
+}
+
+func TestServer_Query_CancelOnError(t *testing.T) {
+	var cancelled atomic.Bool
+	r := simplejson.New(map[string]simplejson.Handler{
+		"fail": queryHandler(func(_ context.Context, _ simplejson.QueryRequest) (simplejson.Response, error) {
+			return nil, errors.New("failed")
+		}),
+		"slow": queryHandler(func(ctx context.Context, _ simplejson.QueryRequest) (simplejson.Response, error) {
+			select {
+			case <-ctx.Done():
+				cancelled.Store(true)
+				return nil, ctx.Err()
+			case <-time.After(time.Minute):
+				return simplejson.TimeSeriesResponse{Target: "slow"}, nil
+			}
+		}),
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [ { "target": "slow" }, { "target": "fail" } ] }`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "failed to process request: failed\n", w.Body.String())
+	assert.True(t, cancelled.Load())
+}
+
+func TestServer_Query_PartialResults(t *testing.T) {
+	r := simplejson.New(handlers, simplejson.WithPartialResults{}, simplejson.WithQueryMetrics{})
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [
+		{ "target": "B", "refId": "A" },
+		{ "target": "D", "refId": "B" }
+	] }`))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[{"target":"B","datapoints":[[100,1577836800000],[99,1577836860000],[98,1577836920000]]},{"target":"D","refId":"B","datapoints":[],"error":"no handler found for target 'D'"}]
+`, w.Body.String())
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP simplejson_query_failed_count Grafana SimpleJSON server count of failed requests
+# TYPE simplejson_query_failed_count counter
+simplejson_query_failed_count{app="simplejson",target="D",type=""} 1
+`), "simplejson_query_failed_count"))
+}
+
+func TestServer_Query_Timeout(t *testing.T) {
+	slow := queryHandler(func(ctx context.Context, _ simplejson.QueryRequest) (simplejson.Response, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return simplejson.TimeSeriesResponse{Target: "slow"}, nil
+		}
+	})
+
+	testCases := []struct {
+		name     string
+		timeout  simplejson.WithTimeout
+		code     int
+		timedOut bool
+	}{
+		{
+			name:    "no timeout",
+			timeout: simplejson.WithTimeout{},
+			code:    http.StatusOK,
+		},
+		{
+			name:     "server timeout",
+			timeout:  simplejson.WithTimeout{Timeout: 10 * time.Millisecond},
+			code:     http.StatusGatewayTimeout,
+			timedOut: true,
+		},
+		{
+			name:     "target timeout",
+			timeout:  simplejson.WithTimeout{Targets: map[string]time.Duration{"slow": 10 * time.Millisecond}},
+			code:     http.StatusGatewayTimeout,
+			timedOut: true,
+		},
+		{
+			name:    "target override",
+			timeout: simplejson.WithTimeout{Timeout: 10 * time.Millisecond, Targets: map[string]time.Duration{"slow": time.Second}},
+			code:    http.StatusOK,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := simplejson.New(map[string]simplejson.Handler{"slow": slow}, tt.timeout, simplejson.WithQueryMetrics{})
+			reg := prometheus.NewPedanticRegistry()
+			reg.MustRegister(r)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [ { "target": "slow" } ] }`))
+			r.ServeHTTP(w, req)
+			require.Equal(t, tt.code, w.Code)
+
+			if !tt.timedOut {
+				return
+			}
+			assert.Equal(t, "failed to process request: target 'slow' timed out: context deadline exceeded\n", w.Body.String())
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP simplejson_query_failed_count Grafana SimpleJSON server count of failed requests
+# TYPE simplejson_query_failed_count counter
+simplejson_query_failed_count{app="simplejson",target="slow",type=""} 1
+# HELP simplejson_query_timeout_count Grafana SimpleJSON server count of timed out requests
+# TYPE simplejson_query_timeout_count counter
+simplejson_query_timeout_count{app="simplejson",target="slow",type=""} 1
+`), "simplejson_query_failed_count", "simplejson_query_timeout_count"))
+		})
+	}
+}
+
 func BenchmarkServer_Query(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		req, _ := http.NewRequest(http.MethodPost, "", bytes.NewBufferString(`{
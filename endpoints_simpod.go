@@ -0,0 +1,235 @@
+package simplejson
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Metrics implements simPod's /metrics endpoint. It returns all targets, with the payload options that each target supports.
+func (s *Server) Metrics(w http.ResponseWriter, req *http.Request) {
+	var request MetricsRequest
+	handleEndpoint(w, req, &request, func() ([]json.Marshaler, error) {
+		var response []json.Marshaler
+		for _, h := range s.getHandlers() {
+			for _, target := range h.targets(req.Context()) {
+				metric, err := s.metric(req.Context(), target, h.handler.Endpoints().MetricPayloads, request)
+				if err != nil {
+					return nil, err
+				}
+				response = append(response, metric)
+			}
+		}
+		return response, nil
+	})
+}
+
+func (s *Server) metric(ctx context.Context, target string, f MetricPayloadsFunc, request MetricsRequest) (*Metric, error) {
+	metric := Metric{Label: target, Value: target}
+	if f == nil {
+		return &metric, nil
+	}
+
+	var payload json.RawMessage
+	if request.Metric == target {
+		payload = request.Payload
+	}
+
+	ctx, cancel := s.withTimeout(ctx, target)
+	defer cancel()
+	var err error
+	if metric.Payloads, err = f(ctx, target, payload); err != nil {
+		return nil, timeoutError(ctx, target, err)
+	}
+	return &metric, nil
+}
+
+// MetricPayloadOptions implements simPod's /metric-payload-options endpoint. It returns the options for a payload
+// of type "select" or "multi-select".
+func (s *Server) MetricPayloadOptions(w http.ResponseWriter, req *http.Request) {
+	var request MetricPayloadOptionsRequest
+	handleEndpoint(w, req, &request, func() ([]json.Marshaler, error) {
+		handler, params, ok := s.getHandler(request.Metric)
+		if !ok {
+			return nil, fmt.Errorf("no handler found for target '%s'", request.Metric)
+		}
+		f := handler.Endpoints().MetricPayloadOptions
+		if f == nil {
+			return nil, fmt.Errorf("metric payload options not implemented for target '%s'", request.Metric)
+		}
+
+		ctx, cancel := s.withTimeout(withTargetParams(req.Context(), params), request.Metric)
+		defer cancel()
+		options, err := f(ctx, request)
+		if err != nil {
+			return nil, timeoutError(ctx, request.Metric, err)
+		}
+
+		response := make([]json.Marshaler, len(options))
+		for index := range options {
+			response[index] = &options[index]
+		}
+		return response, nil
+	})
+}
+
+// Variable implements simPod's /variable endpoint. If the variable's payload specifies a target, Variable returns the
+// values of that target's handler. Otherwise, it returns the values of all handlers.
+func (s *Server) Variable(w http.ResponseWriter, req *http.Request) {
+	var request VariableRequest
+	handleEndpoint(w, req, &request, func() ([]json.Marshaler, error) {
+		var handlers []targetHandler
+		var params map[string]string
+		if target := request.Target(); target != "" {
+			var handler Handler
+			var ok bool
+			if handler, params, ok = s.getHandler(target); !ok {
+				return nil, fmt.Errorf("no handler found for target '%s'", target)
+			}
+			handlers = []targetHandler{{target: target, handler: handler}}
+		} else {
+			handlers = s.getHandlers()
+		}
+
+		var response []json.Marshaler
+		for _, h := range handlers {
+			f := h.handler.Endpoints().Variable
+			if f == nil {
+				continue
+			}
+			values, err := s.variable(withTargetParams(req.Context(), params), h.target, f, request)
+			if err != nil {
+				return nil, err
+			}
+			for index := range values {
+				response = append(response, &values[index])
+			}
+		}
+		return response, nil
+	})
+}
+
+func (s *Server) variable(ctx context.Context, target string, f VariableFunc, request VariableRequest) ([]Variable, error) {
+	ctx, cancel := s.withTimeout(ctx, target)
+	defer cancel()
+	values, err := f(ctx, request)
+	if err != nil {
+		err = timeoutError(ctx, target, err)
+	}
+	return values, err
+}
+
+// MetricsRequest is a request for simPod's /metrics endpoint. Metric and Payload hold the target and payload currently
+// selected in the query editor.
+type MetricsRequest struct {
+	Metric  string          `json:"metric"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// UnmarshalJSON unmarshalls a MetricsRequest from JSON
+func (r *MetricsRequest) UnmarshalJSON(b []byte) (err error) {
+	type request2 MetricsRequest
+	var c request2
+	if err = json.Unmarshal(b, &c); err == nil {
+		*r = MetricsRequest(c)
+	}
+	return err
+}
+
+// Metric is a target returned by the /metrics endpoint, with the payload options it supports.
+type Metric struct {
+	Label    string          `json:"label,omitempty"`
+	Value    string          `json:"value"`
+	Payloads []MetricPayload `json:"payloads,omitempty"`
+}
+
+// MarshalJSON converts a Metric to JSON.
+func (m Metric) MarshalJSON() ([]byte, error) {
+	type metric2 Metric
+	return json.Marshal(metric2(m))
+}
+
+// MetricPayload describes one payload option of a target, shown in the query editor.
+type MetricPayload struct {
+	Label        string                `json:"label,omitempty"`
+	Name         string                `json:"name"`
+	Type         string                `json:"type"` // "select", "multi-select", "input" or "textarea"
+	Placeholder  string                `json:"placeholder,omitempty"`
+	ReloadMetric bool                  `json:"reloadMetric,omitempty"` // reload the metric's payloads when this option changes
+	Width        int                   `json:"width,omitempty"`
+	Options      []MetricPayloadOption `json:"options,omitempty"` // options for a "select" or "multi-select" payload
+}
+
+// MetricPayloadOption is one option of a "select" or "multi-select" payload.
+type MetricPayloadOption struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// MarshalJSON converts a MetricPayloadOption to JSON.
+func (o MetricPayloadOption) MarshalJSON() ([]byte, error) {
+	type option2 MetricPayloadOption
+	return json.Marshal(option2(o))
+}
+
+// MetricPayloadOptionsRequest is a request for simPod's /metric-payload-options endpoint. It asks for the options of payload Name
+// of target Metric, given the currently selected Payload.
+type MetricPayloadOptionsRequest struct {
+	Metric  string          `json:"metric"`
+	Payload json.RawMessage `json:"payload"`
+	Name    string          `json:"name"`
+}
+
+// UnmarshalJSON unmarshalls a MetricPayloadOptionsRequest from JSON
+func (r *MetricPayloadOptionsRequest) UnmarshalJSON(b []byte) (err error) {
+	type request2 MetricPayloadOptionsRequest
+	var c request2
+	if err = json.Unmarshal(b, &c); err == nil {
+		*r = MetricPayloadOptionsRequest(c)
+	}
+	return err
+}
+
+// VariableRequest is a request for simPod's /variable endpoint. Payload holds the variable's query, as entered in the variable editor.
+type VariableRequest struct {
+	Payload json.RawMessage `json:"payload"`
+	Args
+}
+
+// UnmarshalJSON unmarshalls a VariableRequest from JSON
+func (r *VariableRequest) UnmarshalJSON(b []byte) (err error) {
+	type request2 VariableRequest
+	var c request2
+	if err = json.Unmarshal(b, &c); err == nil {
+		*r = VariableRequest(c)
+	}
+	return err
+}
+
+// Target returns the target specified in the variable's payload, i.e. {"target": "<name>"}. If the payload does not
+// specify a target, Target returns an empty string.
+func (r VariableRequest) Target() string {
+	var payload struct {
+		Target string `json:"target"`
+	}
+	_ = json.Unmarshal(r.Payload, &payload)
+	return payload.Target
+}
+
+// Variable is a value returned by the /variable endpoint.
+type Variable struct {
+	Text  string
+	Value string
+}
+
+// MarshalJSON converts a Variable to JSON.
+func (v Variable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Text  string `json:"__text"`
+		Value string `json:"__value"`
+	}{
+		Text:  v.Text,
+		Value: v.Value,
+	})
+}
@@ -0,0 +1,178 @@
+package simplejson_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/clambin/simplejson/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	r := simplejson.New(map[string]simplejson.Handler{
+		"A":   handlers["A"],
+		"cpu": simPodHandler{},
+	})
+	require.NoError(t, r.RegisterPattern(`disk\.(?P<host>.+)`, simPodHandler{}))
+	failing := simplejson.New(map[string]simplejson.Handler{
+		"cpu":  simPodHandler{},
+		"fail": simPodHandler{fail: true},
+	})
+
+	testCases := []struct {
+		name    string
+		server  *simplejson.Server
+		path    string
+		request string
+		code    int
+		want    string
+	}{
+		{
+			name:    "metrics",
+			path:    "/metrics",
+			request: `{"metric": "cpu", "payload": {"host": "host-01"}}`,
+			code:    http.StatusOK,
+			want: `[{"label":"A","value":"A"},{"label":"cpu","value":"cpu","payloads":[{"label":"Host","name":"host","type":"select","reloadMetric":true},{"name":"core","type":"input","placeholder":"host-01"}]}]
+`,
+		},
+		{
+			name:    "metrics - failure",
+			server:  failing,
+			path:    "/metrics",
+			request: `{}`,
+			code:    http.StatusInternalServerError,
+			want:    "failed to process request: metric payloads failed\n",
+		},
+		{
+			name:    "payload options",
+			path:    "/metric-payload-options",
+			request: `{"metric": "cpu", "payload": {}, "name": "host"}`,
+			code:    http.StatusOK,
+			want: `[{"label":"host 1","value":"host-01"},{"label":"host 2","value":"host-02"}]
+`,
+		},
+		{
+			name:    "payload options - unknown target",
+			path:    "/metric-payload-options",
+			request: `{"metric": "mem", "payload": {}, "name": "host"}`,
+			code:    http.StatusInternalServerError,
+			want:    "failed to process request: no handler found for target 'mem'\n",
+		},
+		{
+			name:    "payload options - not implemented",
+			path:    "/metric-payload-options",
+			request: `{"metric": "A", "payload": {}, "name": "host"}`,
+			code:    http.StatusInternalServerError,
+			want:    "failed to process request: metric payload options not implemented for target 'A'\n",
+		},
+		{
+			name:    "variable",
+			path:    "/variable",
+			request: `{"payload": {"target": "cpu"}, "range": {"from": "2020-01-01T00:00:00.000Z", "to": "2020-12-31T00:00:00.000Z"}}`,
+			code:    http.StatusOK,
+			want: `[{"__text":"host 1","__value":"host-01"}]
+`,
+		},
+		{
+			name:    "variable - pattern",
+			path:    "/variable",
+			request: `{"payload": {"target": "disk.host-02"}}`,
+			code:    http.StatusOK,
+			want: `[{"__text":"host-02","__value":"host-02"}]
+`,
+		},
+		{
+			name:    "variable - all targets",
+			server:  failing,
+			path:    "/variable",
+			request: `{"payload": {}}`,
+			code:    http.StatusInternalServerError,
+			want:    "failed to process request: variable failed\n",
+		},
+		{
+			name:    "variable - unknown target",
+			path:    "/variable",
+			request: `{"payload": {"target": "mem"}}`,
+			code:    http.StatusInternalServerError,
+			want:    "failed to process request: no handler found for target 'mem'\n",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			server := r
+			if tt.server != nil {
+				server = tt.server
+			}
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.request))
+			server.ServeHTTP(w, req)
+			require.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.want, w.Body.String())
+		})
+	}
+}
+
+func TestQueryRequest_Payload(t *testing.T) {
+	var request simplejson.QueryRequest
+	err := json.Unmarshal([]byte(`{"targets": [{"target": "cpu", "refId": "A", "payload": {"host": "host-01"}}]}`), &request)
+	require.NoError(t, err)
+	require.Len(t, request.Targets, 1)
+	assert.Equal(t, "A", request.Targets[0].RefID)
+	assert.JSONEq(t, `{"host": "host-01"}`, string(request.Targets[0].Payload))
+}
+
+type simPodHandler struct {
+	fail bool
+}
+
+func (h simPodHandler) Endpoints() simplejson.Endpoints {
+	return simplejson.Endpoints{
+		MetricPayloads:       h.metricPayloads,
+		MetricPayloadOptions: h.metricPayloadOptions,
+		Variable:             h.variable,
+	}
+}
+
+func (h simPodHandler) metricPayloads(_ context.Context, _ string, payload json.RawMessage) ([]simplejson.MetricPayload, error) {
+	if h.fail {
+		return nil, errors.New("metric payloads failed")
+	}
+	var current struct {
+		Host string `json:"host"`
+	}
+	if payload != nil {
+		if err := json.Unmarshal(payload, &current); err != nil {
+			return nil, err
+		}
+	}
+	return []simplejson.MetricPayload{
+		{Label: "Host", Name: "host", Type: "select", ReloadMetric: true},
+		{Name: "core", Type: "input", Placeholder: current.Host},
+	}, nil
+}
+
+func (h simPodHandler) metricPayloadOptions(_ context.Context, req simplejson.MetricPayloadOptionsRequest) ([]simplejson.MetricPayloadOption, error) {
+	if req.Name != "host" {
+		return nil, errors.New("invalid payload name")
+	}
+	return []simplejson.MetricPayloadOption{
+		{Label: "host 1", Value: "host-01"},
+		{Label: "host 2", Value: "host-02"},
+	}, nil
+}
+
+func (h simPodHandler) variable(ctx context.Context, _ simplejson.VariableRequest) ([]simplejson.Variable, error) {
+	if h.fail {
+		return nil, errors.New("variable failed")
+	}
+	if host, ok := simplejson.TargetParams(ctx)["host"]; ok {
+		return []simplejson.Variable{{Text: host, Value: host}}, nil
+	}
+	return []simplejson.Variable{{Text: "host 1", Value: "host-01"}}, nil
+}
@@ -2,6 +2,12 @@ package simplejson_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/clambin/simplejson/v6"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io"
@@ -9,8 +15,10 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSearch(t *testing.T) {
@@ -128,3 +136,273 @@ func TestTagValues(t *testing.T) {
 		})
 	}
 }
+
+func TestTagValues_Timeout(t *testing.T) {
+	r := simplejson.New(map[string]simplejson.Handler{"slow": slowTagsHandler{}}, simplejson.WithTimeout{Timeout: 10 * time.Millisecond})
+
+	for path, body := range map[string]string{"/tag-keys": "", "/tag-values": `{"key": "foo"}`} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code, path)
+		assert.Equal(t, "failed to process request: target 'slow' timed out: context deadline exceeded\n", w.Body.String(), path)
+	}
+}
+
+type slowTagsHandler struct{}
+
+func (h slowTagsHandler) Endpoints() simplejson.Endpoints {
+	return simplejson.Endpoints{
+		TagKeys: func(ctx context.Context) []string {
+			<-ctx.Done()
+			return nil
+		},
+		TagValues: func(ctx context.Context, _ string) ([]string, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+}
+
+// annotationsHandler is a Handler that only implements the /annotations endpoint
+type annotationsHandler simplejson.AnnotationsContextFunc
+
+func (a annotationsHandler) Endpoints() simplejson.Endpoints {
+	return simplejson.Endpoints{AnnotationsContext: simplejson.AnnotationsContextFunc(a)}
+}
+
+func TestServer_Annotations_Context(t *testing.T) {
+	h := simplejson.Endpoints{
+		Annotations: func(_ simplejson.AnnotationRequest) ([]simplejson.Annotation, error) {
+			return []simplejson.Annotation{{Title: "without context"}}, nil
+		},
+		AnnotationsContext: func(ctx context.Context, _ simplejson.AnnotationRequest) ([]simplejson.Annotation, error) {
+			target, _ := simplejson.TargetFromContext(ctx)
+			return []simplejson.Annotation{{Title: target.Name}}, nil
+		},
+	}
+	r := simplejson.New(map[string]simplejson.Handler{"A": endpointsHandler(h)})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/annotations", bytes.NewBufferString(`{ "annotation": { "query": "A" } }`))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"title":"A"`)
+}
+
+// endpointsHandler is a Handler that implements the specified endpoints
+type endpointsHandler simplejson.Endpoints
+
+func (e endpointsHandler) Endpoints() simplejson.Endpoints {
+	return simplejson.Endpoints(e)
+}
+
+func TestServer_Annotations_Errors(t *testing.T) {
+	succeeds := annotationsHandler(func(_ context.Context, _ simplejson.AnnotationRequest) ([]simplejson.Annotation, error) {
+		return []simplejson.Annotation{{Time: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC), Title: "foo"}}, nil
+	})
+	fails := annotationsHandler(func(_ context.Context, _ simplejson.AnnotationRequest) ([]simplejson.Annotation, error) {
+		return nil, errors.New("failed")
+	})
+	slow := annotationsHandler(func(ctx context.Context, _ simplejson.AnnotationRequest) ([]simplejson.Annotation, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	testCases := []struct {
+		name     string
+		handlers map[string]simplejson.Handler
+		options  []simplejson.Option
+		want     int
+		count    int
+		failures string
+	}{
+		{
+			name:     "skip",
+			handlers: map[string]simplejson.Handler{"A": succeeds, "B": fails},
+			want:     http.StatusOK,
+			count:    1,
+			failures: `simplejson_annotations_failed_count{app="simplejson",reason="error",target="B"} 1`,
+		},
+		{
+			name:     "skip - all failed",
+			handlers: map[string]simplejson.Handler{"B": fails},
+			want:     http.StatusOK,
+			count:    0,
+			failures: `simplejson_annotations_failed_count{app="simplejson",reason="error",target="B"} 1`,
+		},
+		{
+			name:     "fail",
+			handlers: map[string]simplejson.Handler{"A": succeeds, "B": fails},
+			options:  []simplejson.Option{simplejson.WithAnnotationErrorPolicy{Policy: simplejson.AnnotationErrorFail}},
+			want:     http.StatusInternalServerError,
+			failures: `simplejson_annotations_failed_count{app="simplejson",reason="error",target="B"} 1`,
+		},
+		{
+			name:     "partial",
+			handlers: map[string]simplejson.Handler{"A": succeeds, "B": fails},
+			options:  []simplejson.Option{simplejson.WithAnnotationErrorPolicy{Policy: simplejson.AnnotationErrorPartial}},
+			want:     http.StatusOK,
+			count:    1,
+			failures: `simplejson_annotations_failed_count{app="simplejson",reason="error",target="B"} 1`,
+		},
+		{
+			name:     "partial - all failed",
+			handlers: map[string]simplejson.Handler{"B": fails},
+			options:  []simplejson.Option{simplejson.WithAnnotationErrorPolicy{Policy: simplejson.AnnotationErrorPartial}},
+			want:     http.StatusInternalServerError,
+			failures: `simplejson_annotations_failed_count{app="simplejson",reason="error",target="B"} 1`,
+		},
+		{
+			name:     "timeout",
+			handlers: map[string]simplejson.Handler{"A": succeeds, "C": slow},
+			options: []simplejson.Option{
+				simplejson.WithAnnotationErrorPolicy{Policy: simplejson.AnnotationErrorFail},
+				simplejson.WithTimeout{Timeout: 10 * time.Millisecond},
+			},
+			want:     http.StatusGatewayTimeout,
+			failures: `simplejson_annotations_failed_count{app="simplejson",reason="timeout",target="C"} 1`,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := simplejson.New(tt.handlers, append(tt.options, simplejson.WithQueryMetrics{})...)
+			reg := prometheus.NewPedanticRegistry()
+			reg.MustRegister(r)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/annotations", bytes.NewBufferString(`{ "annotation": { "name": "snafu" } }`))
+			r.ServeHTTP(w, req)
+			require.Equal(t, tt.want, w.Code)
+
+			if tt.want == http.StatusOK {
+				var annotations []json.RawMessage
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &annotations))
+				assert.Len(t, annotations, tt.count)
+			}
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP simplejson_annotations_failed_count Grafana SimpleJSON server count of failed annotations requests
+# TYPE simplejson_annotations_failed_count counter
+`+tt.failures+`
+`), "simplejson_annotations_failed_count"))
+		})
+	}
+}
+
+func TestServer_Annotations_Query(t *testing.T) {
+	h := annotationsHandler(func(ctx context.Context, _ simplejson.AnnotationRequest) ([]simplejson.Annotation, error) {
+		target, ok := simplejson.TargetFromContext(ctx)
+		require.True(t, ok)
+		params := simplejson.TargetParams(ctx)
+		keys := make([]string, 0, len(params))
+		for key := range params {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var text []string
+		for _, key := range keys {
+			text = append(text, key+"="+params[key])
+		}
+		return []simplejson.Annotation{{Title: target.Name, Text: strings.Join(text, ",")}}, nil
+	})
+	r := simplejson.New(map[string]simplejson.Handler{"deployments": h})
+	require.NoError(t, r.RegisterPattern(`outages\.(?P<region>.+)`, endpointsHandler{
+		AnnotationsContext: simplejson.AnnotationsContextFunc(h),
+		Targets:            func(_ context.Context) []string { return []string{"outages.eu", "outages.us"} },
+	}))
+	// without a Targets endpoint, a pattern handler is only called if the query names one of its targets
+	require.NoError(t, r.RegisterPattern(`incidents\..+`, h))
+
+	testCases := []struct {
+		name  string
+		query string
+		want  int
+		texts []string
+	}{
+		{name: "all", query: "", want: http.StatusOK, texts: []string{"deployments:", "outages.eu:region=eu", "outages.us:region=us"}},
+		{name: "all - parameters", query: "env=prod", want: http.StatusOK, texts: []string{"deployments:env=prod", "outages.eu:env=prod,region=eu", "outages.us:env=prod,region=us"}},
+		{name: "target", query: "deployments", want: http.StatusOK, texts: []string{"deployments:"}},
+		{name: "parameters", query: "deployments env=prod  flag", want: http.StatusOK, texts: []string{"deployments:env=prod,flag="}},
+		{name: "pattern", query: "outages.eu severity=high", want: http.StatusOK, texts: []string{"outages.eu:region=eu,severity=high"}},
+		{name: "pattern without targets", query: "incidents.db", want: http.StatusOK, texts: []string{"incidents.db:"}},
+		{name: "free text", query: "show deploys", want: http.StatusOK, texts: []string{"deployments:deploys=", "outages.eu:deploys=,region=eu", "outages.us:deploys=,region=us"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/annotations", bytes.NewBufferString(`{ "annotation": { "name": "snafu", "query": "`+tt.query+`" } }`))
+			r.ServeHTTP(w, req)
+			require.Equal(t, tt.want, w.Code)
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var annotations []struct {
+				Title string `json:"title"`
+				Text  string `json:"text"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &annotations))
+			var texts []string
+			for _, annotation := range annotations {
+				texts = append(texts, annotation.Title+":"+annotation.Text)
+			}
+			assert.Equal(t, tt.texts, texts)
+		})
+	}
+}
+
+func TestWithAnnotationFilter(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	h := annotationsHandler(func(_ context.Context, _ simplejson.AnnotationRequest) ([]simplejson.Annotation, error) {
+		return []simplejson.Annotation{
+			{Time: day(2022, time.January, 1), Title: "a1", Tags: []string{"deploy", "prod"}},
+			{Time: day(2022, time.February, 1), Title: "a2", Tags: []string{"deploy"}},
+			{Time: day(2021, time.December, 1), TimeEnd: day(2022, time.January, 15), Title: "a3", Tags: []string{"outage"}},
+			{Time: day(2021, time.November, 1), TimeEnd: day(2021, time.November, 15), Title: "a4", Tags: []string{"outage"}},
+			{Time: day(2023, time.January, 1), Title: "a5", Tags: []string{"prod"}},
+		}, nil
+	})
+
+	testCases := []struct {
+		name   string
+		filter simplejson.WithAnnotationFilter
+		query  string
+		want   []string
+	}{
+		{name: "none", query: "tags=deploy", want: []string{"a1", "a2", "a3", "a4", "a5"}},
+		{name: "range", filter: simplejson.WithAnnotationFilter{Range: true}, query: "tags=deploy", want: []string{"a1", "a2", "a3"}},
+		{name: "tags", filter: simplejson.WithAnnotationFilter{Tags: true}, query: "tags=deploy", want: []string{"a1", "a2"}},
+		{name: "tags with target", filter: simplejson.WithAnnotationFilter{Tags: true}, query: "events tags=deploy,prod", want: []string{"a1"}},
+		{name: "no tags", filter: simplejson.WithAnnotationFilter{Tags: true}, query: "events", want: []string{"a1", "a2", "a3", "a4", "a5"}},
+		{name: "both", filter: simplejson.WithAnnotationFilter{Range: true, Tags: true}, query: "tags=prod", want: []string{"a1"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := simplejson.New(map[string]simplejson.Handler{"events": h}, tt.filter)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/annotations", bytes.NewBufferString(`{
+	"range": { "from": "2022-01-01T00:00:00.000Z", "to": "2022-03-01T00:00:00.000Z" },
+	"annotation": { "name": "snafu", "query": "`+tt.query+`" }
+}`))
+			r.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var annotations []struct {
+				Title string `json:"title"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &annotations))
+			var titles []string
+			for _, annotation := range annotations {
+				titles = append(titles, annotation.Title)
+			}
+			assert.Equal(t, tt.want, titles)
+		})
+	}
+}
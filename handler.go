@@ -1,6 +1,9 @@
 package simplejson
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
 
 // Handler implements the different Grafana SimpleJSON endpoints.  The interface only contains a single Endpoints() function,
 // so that a handler only has to implement the endpoint functions (query, annotation, etc.) that it needs.
@@ -10,20 +13,67 @@ type Handler interface {
 
 // Endpoints contains the functions that implement each of the SimpleJson endpoints
 type Endpoints struct {
-	Query       QueryFunc       // /query endpoint: handles queries
-	Annotations AnnotationsFunc // /annotation endpoint: handles requests for annotation
-	TagKeys     TagKeysFunc     // /tag-keys endpoint: returns all supported tag names
-	TagValues   TagValuesFunc   // /tag-values endpoint: returns all supported values for the specified tag name
+	Query              QueryFunc              // /query endpoint: handles queries
+	Annotations        AnnotationsFunc        // /annotation endpoint: handles requests for annotation
+	AnnotationsContext AnnotationsContextFunc // /annotation endpoint: handles requests for annotation, with a context. Takes precedence over Annotations
+	TagKeys            TagKeysFunc            // /tag-keys endpoint: returns all supported tag names
+	TagValues          TagValuesFunc          // /tag-values endpoint: returns all supported values for the specified tag name
+	Targets            TargetsFunc            // /search endpoint: returns the targets served by a handler registered through RegisterPattern
+	Search             SearchFunc             // /search endpoint: returns the targets (or template variable values) matching the search term
+	SearchValues       SearchValuesFunc       // /search endpoint: returns text/value pairs matching the search term. Takes precedence over Search. See WithSearchValues
+
+	MetricPayloads       MetricPayloadsFunc       // /metrics endpoint (simPod): returns the payload options of a target
+	MetricPayloadOptions MetricPayloadOptionsFunc // /metric-payload-options endpoint (simPod): returns the options of a payload
+	Variable             VariableFunc             // /variable endpoint (simPod): returns the values of a template variable
 }
 
-// QueryFunc handles queries
+// QueryFunc handles queries. Use TargetFromContext to determine the target being served.
 type QueryFunc func(ctx context.Context, req QueryRequest) (Response, error)
 
 // AnnotationsFunc handles requests for annotation
 type AnnotationsFunc func(req AnnotationRequest) ([]Annotation, error)
 
+// AnnotationsContextFunc handles requests for annotation. Use TargetFromContext and TargetParams to determine the target
+// being served. The context is cancelled when the request times out (see WithTimeout).
+type AnnotationsContextFunc func(ctx context.Context, req AnnotationRequest) ([]Annotation, error)
+
 // TagKeysFunc returns supported tag names
 type TagKeysFunc func(ctx context.Context) []string
 
 // TagValuesFunc returns supported values for the specified tag name
 type TagValuesFunc func(ctx context.Context, key string) ([]string, error)
+
+// TargetsFunc returns the targets served by a handler registered for a pattern
+type TargetsFunc func(ctx context.Context) []string
+
+// SearchFunc returns the targets matching the search term sent by Grafana (e.g. while the user is typing a target name,
+// or when Grafana resolves a query template variable). The search term may be empty.
+type SearchFunc func(ctx context.Context, term string) ([]string, error)
+
+// SearchValuesFunc returns the text/value pairs matching the search term sent by Grafana, e.g. to show a friendly label
+// in a template variable's dropdown while querying by ID. The search term may be empty.
+type SearchValuesFunc func(ctx context.Context, term string) ([]SearchValue, error)
+
+// MetricPayloadsFunc returns the payload options that the query editor shows for the target. Payload holds the currently
+// selected payload, if the target is currently selected in the query editor.
+type MetricPayloadsFunc func(ctx context.Context, target string, payload json.RawMessage) ([]MetricPayload, error)
+
+// MetricPayloadOptionsFunc returns the options for a payload of type "select" or "multi-select"
+type MetricPayloadOptionsFunc func(ctx context.Context, req MetricPayloadOptionsRequest) ([]MetricPayloadOption, error)
+
+// VariableFunc returns the values for a template variable
+type VariableFunc func(ctx context.Context, req VariableRequest) ([]Variable, error)
+
+// annotations returns the endpoint's AnnotationsContext function or, if not set, its Annotations function.
+// If the handler doesn't support annotations, annotations returns nil.
+func (e Endpoints) annotations() AnnotationsContextFunc {
+	if e.AnnotationsContext != nil {
+		return e.AnnotationsContext
+	}
+	if e.Annotations != nil {
+		return func(_ context.Context, req AnnotationRequest) ([]Annotation, error) {
+			return e.Annotations(req)
+		}
+	}
+	return nil
+}
@@ -1,20 +1,30 @@
 package simplejson
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 type QueryMetrics struct {
-	duration *prometheus.HistogramVec
-	errors   *prometheus.CounterVec
+	duration          *prometheus.HistogramVec
+	errors            *prometheus.CounterVec
+	timeouts          *prometheus.CounterVec
+	annotationsErrors *prometheus.CounterVec
 }
 
 func (qm QueryMetrics) Describe(ch chan<- *prometheus.Desc) {
 	qm.duration.Describe(ch)
 	qm.errors.Describe(ch)
+	qm.timeouts.Describe(ch)
+	qm.annotationsErrors.Describe(ch)
 }
 
 func (qm QueryMetrics) Collect(ch chan<- prometheus.Metric) {
 	qm.duration.Collect(ch)
 	qm.errors.Collect(ch)
+	qm.timeouts.Collect(ch)
+	qm.annotationsErrors.Collect(ch)
 }
 
 func newQueryMetrics(name string) *QueryMetrics {
@@ -30,6 +40,36 @@ func newQueryMetrics(name string) *QueryMetrics {
 			Help:        "Grafana SimpleJSON server count of failed requests",
 			ConstLabels: prometheus.Labels{"app": name},
 		}, []string{"target", "type"}),
+		timeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName("simplejson", "query", "timeout_count"),
+			Help:        "Grafana SimpleJSON server count of timed out requests",
+			ConstLabels: prometheus.Labels{"app": name},
+		}, []string{"target", "type"}),
+		annotationsErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName("simplejson", "annotations", "failed_count"),
+			Help:        "Grafana SimpleJSON server count of failed annotations requests",
+			ConstLabels: prometheus.Labels{"app": name},
+		}, []string{"target", "reason"}),
 	}
 	return &qm
 }
+
+// observeError records a failed query. Timeouts are also counted separately.
+func (qm QueryMetrics) observeError(target Target, err error) {
+	qm.errors.WithLabelValues(target.Name, target.Type).Add(1.0)
+	if errors.Is(err, context.DeadlineExceeded) {
+		qm.timeouts.WithLabelValues(target.Name, target.Type).Add(1.0)
+	}
+}
+
+// observeAnnotationsError records a failed Annotations call.
+func (qm QueryMetrics) observeAnnotationsError(target string, err error) {
+	qm.annotationsErrors.WithLabelValues(target, errorReason(err)).Add(1.0)
+}
+
+func errorReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "error"
+}
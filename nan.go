@@ -0,0 +1,129 @@
+package simplejson
+
+import "math"
+
+// NaNPolicy determines how a TimeSeriesResponse (or NullableTimeSeriesResponse) handles data points whose value is NaN or infinite. These are not valid JSON.
+type NaNPolicy int
+
+const (
+	// NaNAsNull sends NaN and infinite values as null, so Grafana shows a gap. This is the default.
+	NaNAsNull NaNPolicy = iota
+	// NaNDrop removes data points with a NaN or infinite value.
+	NaNDrop
+	// NaNClamp replaces infinite values by the highest (or lowest) finite value of the timeseries, so they don't stretch
+	// the panel's y-axis. If the timeseries has no finite values, or for NaN values, the data point is still sent as null.
+	NaNClamp
+)
+
+// ApplyNaNPolicy returns a TimeSeriesResponse where the data points with a NaN or infinite value are handled according to the policy.
+// The response's data points are not modified.
+func (r TimeSeriesResponse) ApplyNaNPolicy(policy NaNPolicy) TimeSeriesResponse {
+	switch policy {
+	case NaNDrop:
+		dataPoints := make([]DataPoint, 0, len(r.DataPoints))
+		for _, d := range r.DataPoints {
+			if !math.IsNaN(d.Value) && !math.IsInf(d.Value, 0) {
+				dataPoints = append(dataPoints, d)
+			}
+		}
+		r.DataPoints = dataPoints
+	case NaNClamp:
+		values := make([]float64, len(r.DataPoints))
+		for i, d := range r.DataPoints {
+			values[i] = d.Value
+		}
+		low, high := finiteRange(values)
+		dataPoints := make([]DataPoint, len(r.DataPoints))
+		for i, d := range r.DataPoints {
+			d.Value = clamp(d.Value, low, high)
+			dataPoints[i] = d
+		}
+		r.DataPoints = dataPoints
+	}
+	return r
+}
+
+// ApplyNaNPolicy returns a NullableTimeSeriesResponse where the data points with a NaN or infinite value are handled according
+// to the policy. Missing values are not affected. The response's data points are not modified.
+func (r NullableTimeSeriesResponse) ApplyNaNPolicy(policy NaNPolicy) NullableTimeSeriesResponse {
+	switch policy {
+	case NaNDrop:
+		dataPoints := make([]NullableDataPoint, 0, len(r.DataPoints))
+		for _, d := range r.DataPoints {
+			if d.Value == nil || !math.IsNaN(*d.Value) && !math.IsInf(*d.Value, 0) {
+				dataPoints = append(dataPoints, d)
+			}
+		}
+		r.DataPoints = dataPoints
+	case NaNClamp:
+		values := make([]float64, 0, len(r.DataPoints))
+		for _, d := range r.DataPoints {
+			if d.Value != nil {
+				values = append(values, *d.Value)
+			}
+		}
+		low, high := finiteRange(values)
+		dataPoints := make([]NullableDataPoint, len(r.DataPoints))
+		for i, d := range r.DataPoints {
+			if d.Value != nil && math.IsInf(*d.Value, 0) {
+				value := clamp(*d.Value, low, high)
+				d.Value = &value
+			}
+			dataPoints[i] = d
+		}
+		r.DataPoints = dataPoints
+	}
+	return r
+}
+
+// finiteRange returns the lowest and highest finite value. If there are no finite values, it returns -Inf and +Inf.
+func finiteRange(values []float64) (low, high float64) {
+	low, high = math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+	if math.IsInf(low, 1) {
+		return math.Inf(-1), math.Inf(1)
+	}
+	return low, high
+}
+
+// clamp replaces an infinite value by high (for +Inf) or low (for -Inf)
+func clamp(value, low, high float64) float64 {
+	switch {
+	case math.IsInf(value, 1):
+		return high
+	case math.IsInf(value, -1):
+		return low
+	default:
+		return value
+	}
+}
+
+// applyNaNPolicy applies the Server's NaNPolicy to a timeseries response.
+func (s *Server) applyNaNPolicy(response Response) Response {
+	if s.nanPolicy == NaNAsNull {
+		return response
+	}
+	switch r := response.(type) {
+	case TimeSeriesResponse:
+		return r.ApplyNaNPolicy(s.nanPolicy)
+	case *TimeSeriesResponse:
+		if r != nil {
+			output := r.ApplyNaNPolicy(s.nanPolicy)
+			return &output
+		}
+	case NullableTimeSeriesResponse:
+		return r.ApplyNaNPolicy(s.nanPolicy)
+	case *NullableTimeSeriesResponse:
+		if r != nil {
+			output := r.ApplyNaNPolicy(s.nanPolicy)
+			return &output
+		}
+	}
+	return response
+}
@@ -0,0 +1,129 @@
+package simplejson_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/clambin/simplejson/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDataPoint_MarshalJSON(t *testing.T) {
+	response := makeTimeSeriesResponse(1, math.NaN(), math.Inf(1), math.Inf(-1))
+	output, err := response.MarshalJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"target":"foo","datapoints":[[1,1669507200000],[null,1669507260000],[null,1669507320000],[null,1669507380000]]}`, string(output))
+}
+
+func TestNullableTimeSeriesResponse_MarshalJSON(t *testing.T) {
+	value := 1.0
+	nan := math.NaN()
+	timestamp := time.Date(2022, time.November, 27, 0, 0, 0, 0, time.UTC)
+	response := simplejson.NullableTimeSeriesResponse{
+		Target: "foo",
+		DataPoints: []simplejson.NullableDataPoint{
+			{Timestamp: timestamp, Value: &value},
+			{Timestamp: timestamp.Add(time.Minute)},
+			{Timestamp: timestamp.Add(2 * time.Minute), Value: &nan},
+		},
+	}
+	output, err := response.MarshalJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"target":"foo","datapoints":[[1,1669507200000],[null,1669507260000],[null,1669507320000]]}`, string(output))
+}
+
+func TestTimeSeriesResponse_ApplyNaNPolicy(t *testing.T) {
+	input := makeTimeSeriesResponse(1, math.NaN(), math.Inf(1), math.Inf(-1))
+
+	testCases := []struct {
+		name   string
+		policy simplejson.NaNPolicy
+		want   []float64
+	}{
+		{name: "null", policy: simplejson.NaNAsNull, want: []float64{1, math.NaN(), math.Inf(1), math.Inf(-1)}},
+		{name: "drop", policy: simplejson.NaNDrop, want: []float64{1}},
+		{name: "clamp", policy: simplejson.NaNClamp, want: []float64{1, math.NaN(), 1, 1}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			output := input.ApplyNaNPolicy(tt.policy)
+			require.Len(t, output.DataPoints, len(tt.want))
+			for i, d := range output.DataPoints {
+				if math.IsNaN(tt.want[i]) {
+					assert.True(t, math.IsNaN(d.Value))
+				} else {
+					assert.Equal(t, tt.want[i], d.Value)
+				}
+			}
+		})
+	}
+
+	// the input is not modified
+	assert.True(t, math.IsInf(input.DataPoints[2].Value, 1))
+}
+
+func TestTimeSeriesResponse_ApplyNaNPolicy_Clamp(t *testing.T) {
+	output := makeTimeSeriesResponse(2, math.Inf(1), -3, math.Inf(-1), 5).ApplyNaNPolicy(simplejson.NaNClamp)
+	assert.Equal(t, makeTimeSeriesResponse(2, 5, -3, -3, 5), output)
+
+	// without finite values, infinite values are kept (and sent as null)
+	output = makeTimeSeriesResponse(math.Inf(1), math.NaN()).ApplyNaNPolicy(simplejson.NaNClamp)
+	assert.True(t, math.IsInf(output.DataPoints[0].Value, 1))
+}
+
+func TestNullableTimeSeriesResponse_ApplyNaNPolicy(t *testing.T) {
+	input := makeNullableTimeSeriesResponse(pointer(1.0), nil, pointer(math.Inf(1)), pointer(math.NaN()), pointer(3.0))
+
+	output := input.ApplyNaNPolicy(simplejson.NaNDrop)
+	assert.Equal(t, makeSparseNullableTimeSeriesResponse(map[int]*float64{0: pointer(1.0), 1: nil, 4: pointer(3.0)}), output)
+
+	output = input.ApplyNaNPolicy(simplejson.NaNClamp)
+	require.Len(t, output.DataPoints, 5)
+	assert.Nil(t, output.DataPoints[1].Value)
+	assert.Equal(t, 3.0, *output.DataPoints[2].Value)
+	assert.True(t, math.IsNaN(*output.DataPoints[3].Value))
+
+	// the input is not modified
+	assert.True(t, math.IsInf(*input.DataPoints[2].Value, 1))
+}
+
+func TestWithNaNPolicy(t *testing.T) {
+	response := makeTimeSeriesResponse(1, math.NaN(), 3)
+	h := queryHandler(func(_ context.Context, _ simplejson.QueryRequest) (simplejson.Response, error) {
+		return &response, nil
+	})
+	nullable := makeNullableTimeSeriesResponse(pointer(1.0), nil, pointer(math.NaN()))
+	n := queryHandler(func(_ context.Context, _ simplejson.QueryRequest) (simplejson.Response, error) {
+		return nullable, nil
+	})
+
+	testCases := []struct {
+		name    string
+		options []simplejson.Option
+		target  string
+		want    string
+	}{
+		{name: "default", target: "A", want: `[{"target":"foo","datapoints":[[1,1669507200000],[null,1669507260000],[3,1669507320000]]}]`},
+		{name: "drop", options: []simplejson.Option{simplejson.WithNaNPolicy{Policy: simplejson.NaNDrop}}, target: "A", want: `[{"target":"foo","datapoints":[[1,1669507200000],[3,1669507320000]]}]`},
+		{name: "nullable - default", target: "B", want: `[{"target":"foo","datapoints":[[1,1669507200000],[null,1669507260000],[null,1669507320000]]}]`},
+		{name: "nullable - drop", options: []simplejson.Option{simplejson.WithNaNPolicy{Policy: simplejson.NaNDrop}}, target: "B", want: `[{"target":"foo","datapoints":[[1,1669507200000],[null,1669507260000]]}]`},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := simplejson.New(map[string]simplejson.Handler{"A": h, "B": n}, tt.options...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [ { "target": "`+tt.target+`" } ] }`))
+			r.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, tt.want, w.Body.String())
+		})
+	}
+}
@@ -3,6 +3,8 @@ package simplejson
 import (
 	"github.com/clambin/go-common/httpserver/middleware"
 	"golang.org/x/exp/slog"
+	"net/http"
+	"time"
 )
 
 // Option specified configuration options for Server
@@ -39,3 +41,98 @@ type WithLogger struct {
 func (o WithLogger) apply(s *Server) {
 	s.logger = o.Logger
 }
+
+// WithMaxConcurrentQueries limits the number of targets of a single query request that are processed in parallel.
+// If Max is zero, all targets are processed in parallel.
+type WithMaxConcurrentQueries struct {
+	Max int
+}
+
+func (o WithMaxConcurrentQueries) apply(s *Server) {
+	s.maxConcurrentQueries = o.Max
+}
+
+// WithPartialResults configures the Server to return the responses of all successful targets of a query request,
+// even if some targets fail. Failed targets are reported as an empty timeseries, with an error message keyed by the target's refId.
+// Failures are still counted in the simplejson_query_failed_count metric.
+type WithPartialResults struct{}
+
+func (o WithPartialResults) apply(s *Server) {
+	s.partialResults = true
+}
+
+// WithTimeout bounds the context passed to a handler's Query, AnnotationsContext, TagKeys and TagValues functions. Timeout applies to all targets.
+// Targets overrides the timeout for individual targets. If a handler does not complete in time, the request fails with
+// http.StatusGatewayTimeout.
+type WithTimeout struct {
+	Timeout time.Duration
+	Targets map[string]time.Duration
+}
+
+func (o WithTimeout) apply(s *Server) {
+	s.timeout = o.Timeout
+	s.targetTimeouts = o.Targets
+}
+
+// WithDownsampling configures the Server to downsample timeseries responses to the MaxDataPoints of the query request,
+// using the specified Algorithm. Table responses are not downsampled.
+type WithDownsampling struct {
+	Algorithm DownsampleAlgorithm
+}
+
+func (o WithDownsampling) apply(s *Server) {
+	s.downsampling = &o.Algorithm
+}
+
+// WithNaNPolicy configures how the Server handles NaN and infinite values in timeseries responses. By default, these are sent as null.
+type WithNaNPolicy struct {
+	Policy NaNPolicy
+}
+
+func (o WithNaNPolicy) apply(s *Server) {
+	s.nanPolicy = o.Policy
+}
+
+// WithAnnotationErrorPolicy configures how the Server handles errors returned by a handler's Annotations function.
+// Failures are counted in the simplejson_annotations_failed_count metric.
+type WithAnnotationErrorPolicy struct {
+	Policy AnnotationErrorPolicy
+}
+
+func (o WithAnnotationErrorPolicy) apply(s *Server) {
+	s.annotationErrorPolicy = o.Policy
+}
+
+// WithAnnotationFilter configures the Server to filter the annotations returned by the handlers. With Range set, only
+// annotations that overlap with the request's time range (see Annotation.InRange) are returned. With Tags set, only
+// annotations that have all the tags listed in the annotation query's "tags" parameter (e.g. "tags=deploy,prod") are returned.
+type WithAnnotationFilter struct {
+	Range bool
+	Tags  bool
+}
+
+func (o WithAnnotationFilter) apply(s *Server) {
+	s.filterAnnotationsRange = o.Range
+	s.filterAnnotationsTags = o.Tags
+}
+
+// WithSearchValues configures the Server to return the results of the /search endpoint as text/value pairs. Targets, and the
+// results of handlers that implement the Search endpoint, use the same text and value. Without this option, the /search endpoint
+// returns a list of strings, using the Value of the handlers that implement the SearchValues endpoint.
+type WithSearchValues struct{}
+
+func (o WithSearchValues) apply(s *Server) {
+	s.searchValues = true
+}
+
+// WithHTTPHandler mounts an additional http.Handler on the Server under the specified path, e.g. the HTTP API of the
+// annotations package's Store. Unlike mounting it on the Server's router directly, its requests are logged and recorded
+// in the Server's HTTP metrics (see WithHTTPMetrics). The option can be repeated to mount several handlers.
+type WithHTTPHandler struct {
+	Path    string
+	Handler http.Handler
+}
+
+func (o WithHTTPHandler) apply(s *Server) {
+	s.httpHandlers = append(s.httpHandlers, o)
+}
@@ -0,0 +1,92 @@
+package simplejson
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RegisterPattern adds a handler that serves all targets matching the specified regular expression. The expression must match
+// the complete target name. Any (named) groups captured by the expression are passed to the handler's Query, AnnotationsContext,
+// MetricPayloadOptions and Variable functions and can be retrieved with TargetParams. If the pattern already has a handler,
+// it is replaced. Use UnregisterPattern to remove it.
+//
+// Targets with an exact handler (see Register) take precedence over patterns. Patterns are evaluated in the order they were
+// registered. To include the concrete targets served by a pattern handler in the /search endpoint, the handler should implement
+// the Targets endpoint. Targets that don't match the pattern are ignored.
+func (s *Server) RegisterPattern(pattern string, handler Handler) error {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for index := range s.patterns {
+		if s.patterns[index].pattern == pattern {
+			s.patterns[index].handler = handler
+			return nil
+		}
+	}
+	s.patterns = append(s.patterns, targetPattern{pattern: pattern, re: re, handler: handler})
+	return nil
+}
+
+// UnregisterPattern removes the handler for the specified pattern. UnregisterPattern is safe to call while the Server is
+// processing requests.
+func (s *Server) UnregisterPattern(pattern string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for index := range s.patterns {
+		if s.patterns[index].pattern == pattern {
+			s.patterns = append(s.patterns[:index:index], s.patterns[index+1:]...)
+			return
+		}
+	}
+}
+
+// Glob converts a glob pattern into a regular expression that can be used with RegisterPattern. In a glob pattern, '*' matches
+// any sequence of characters and '?' matches any single character. Each wildcard is captured as a numbered parameter.
+//
+// E.g. Glob("cpu.*") matches the target "cpu.host-01", with TargetParams returning {"1": "host-01"}.
+func Glob(pattern string) string {
+	var output strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			output.WriteString("(.*)")
+		case '?':
+			output.WriteString("(.)")
+		default:
+			output.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return output.String()
+}
+
+// targetPattern is a handler registered for all targets matching a regular expression.
+type targetPattern struct {
+	pattern string
+	re      *regexp.Regexp
+	handler Handler
+}
+
+// match returns the parameters captured by the pattern, if the target matches it.
+func (p targetPattern) match(target string) (map[string]string, bool) {
+	matches := p.re.FindStringSubmatch(target)
+	if matches == nil {
+		return nil, false
+	}
+	params := make(map[string]string, len(matches)-1)
+	for index, name := range p.re.SubexpNames() {
+		if index == 0 {
+			continue
+		}
+		if name == "" {
+			name = strconv.Itoa(index)
+		}
+		params[name] = matches[index]
+	}
+	return params, true
+}
@@ -0,0 +1,166 @@
+package simplejson_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/clambin/simplejson/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_RegisterPattern(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pattern string
+		target  string
+		pass    bool
+		params  map[string]string
+	}{
+		{
+			name:    "exact",
+			pattern: "A",
+			target:  "A",
+			pass:    true,
+			params:  map[string]string{},
+		},
+		{
+			name:    "glob",
+			pattern: simplejson.Glob("cpu.*"),
+			target:  "cpu.host-01",
+			pass:    true,
+			params:  map[string]string{"1": "host-01"},
+		},
+		{
+			name:    "glob mismatch",
+			pattern: simplejson.Glob("cpu.*"),
+			target:  "mem.host-01",
+			pass:    false,
+		},
+		{
+			name:    "glob escapes regex characters",
+			pattern: simplejson.Glob("cpu.host-??"),
+			target:  "cpuXhost-01",
+			pass:    false,
+		},
+		{
+			name:    "regex",
+			pattern: `(?P<metric>cpu|mem)\.(?P<host>host-\d+)`,
+			target:  "mem.host-12",
+			pass:    true,
+			params:  map[string]string{"metric": "mem", "host": "host-12"},
+		},
+		{
+			name:    "regex must match the whole target",
+			pattern: `cpu\.host-\d+`,
+			target:  "cpu.host-12.idle",
+			pass:    false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var params map[string]string
+			h := queryHandler(func(ctx context.Context, _ simplejson.QueryRequest) (simplejson.Response, error) {
+				params = simplejson.TargetParams(ctx)
+				return simplejson.TimeSeriesResponse{}, nil
+			})
+			r := simplejson.New(nil)
+			require.NoError(t, r.RegisterPattern(tt.pattern, h))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [ { "target": "`+tt.target+`" } ] }`))
+			r.ServeHTTP(w, req)
+
+			if !tt.pass {
+				assert.Equal(t, http.StatusInternalServerError, w.Code)
+				return
+			}
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.params, params)
+		})
+	}
+}
+
+func TestServer_RegisterPattern_Invalid(t *testing.T) {
+	r := simplejson.New(nil)
+	assert.Error(t, r.RegisterPattern("cpu.(", &testHandler{}))
+}
+
+func TestServer_RegisterPattern_Precedence(t *testing.T) {
+	r := simplejson.New(map[string]simplejson.Handler{"cpu.host-01": handlers["A"]})
+	require.NoError(t, r.RegisterPattern(simplejson.Glob("cpu.*"), handlers["B"]))
+
+	for target, expected := range map[string]string{
+		"cpu.host-01": `[{"target":"A","datapoints":[[100,1577836800000],[101,1577836860000],[103,1577836920000]]}]` + "\n",
+		"cpu.host-02": `[{"target":"B","datapoints":[[100,1577836800000],[99,1577836860000],[98,1577836920000]]}]` + "\n",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [ { "target": "`+target+`" } ] }`))
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, expected, w.Body.String())
+	}
+
+	r.UnregisterPattern(simplejson.Glob("cpu.*"))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [ { "target": "cpu.host-02" } ] }`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestServer_UnregisterPattern(t *testing.T) {
+	r := simplejson.New(map[string]simplejson.Handler{"A": handlers["A"]})
+	require.NoError(t, r.RegisterPattern("A", handlers["B"]))
+
+	query := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [ { "target": "A" } ] }`))
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Unregister only removes the exact target
+	r.Unregister("A")
+	assert.Equal(t, http.StatusOK, query())
+
+	r.UnregisterPattern("A")
+	assert.Equal(t, http.StatusInternalServerError, query())
+}
+
+func TestServer_RegisterPattern_Search(t *testing.T) {
+	r := simplejson.New(map[string]simplejson.Handler{"cpu.host-01": handlers["A"], "mem": handlers["B"]})
+	require.NoError(t, r.RegisterPattern(simplejson.Glob("cpu.*"), patternHandler{"cpu.host-01", "cpu.host-02"}))
+	require.NoError(t, r.RegisterPattern(simplejson.Glob("disk.*"), handlers["C"]))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/search", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `["cpu.host-01","cpu.host-02","mem"]`, w.Body.String())
+}
+
+func TestServer_RegisterPattern_Targets(t *testing.T) {
+	r := simplejson.New(nil)
+	require.NoError(t, r.RegisterPattern(`cpu\.(.+)`, patternHandler{"cpu.a", "mem.x"}))
+
+	for _, path := range []string{"/search", "/metrics"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, path, nil)
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, path)
+		assert.Contains(t, w.Body.String(), `"cpu.a"`, path)
+		assert.NotContains(t, w.Body.String(), `"mem.x"`, path)
+	}
+}
+
+// patternHandler advertises the targets it serves
+type patternHandler []string
+
+func (p patternHandler) Endpoints() simplejson.Endpoints {
+	return simplejson.Endpoints{
+		Targets: func(_ context.Context) []string { return p },
+	}
+}
@@ -0,0 +1,287 @@
+// Package annotations provides a simplejson Handler that serves annotations from a file-backed store. Annotations are
+// added and removed through an HTTP API, e.g. to record deployments or incidents from a CI pipeline:
+//
+//	store, err := annotations.New("/data/annotations.json")
+//	if err != nil {
+//		panic(err)
+//	}
+//	s := simplejson.New(map[string]simplejson.Handler{"events": store},
+//		simplejson.WithHTTPHandler{Path: "/annotations/store", Handler: store.Handler(authorize)},
+//	)
+//
+// This creates the following endpoints:
+//
+//	POST   /annotations/store      adds an annotation, e.g. {"time":"2022-01-01T12:00:00Z","title":"deploy","tags":["prod"]}
+//	DELETE /annotations/store/{id} removes the annotation with the specified ID
+//
+// authorize determines which requests may change the store, e.g. by checking a bearer token:
+//
+//	authorize := func(req *http.Request) error {
+//		if req.Header.Get("Authorization") != "Bearer "+token {
+//			return errors.New("invalid token")
+//		}
+//		return nil
+//	}
+//
+// The stored annotations are returned by the Server's /annotations endpoint.
+package annotations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/clambin/simplejson/v6"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Delete if the store has no annotation with the specified ID.
+var ErrNotFound = errors.New("annotation not found")
+
+// ErrInvalid is returned by Add if the annotation is not valid, i.e. it has no title, or it ends before it starts.
+var ErrInvalid = errors.New("invalid annotation")
+
+// MaxRequestSize is the maximum size of a request body accepted by the Store's HTTP API.
+const MaxRequestSize = 64 * 1024
+
+// Store holds annotations and saves them to a file. Store implements simplejson.Handler: it returns the annotations that
+// overlap with the time range of the annotation request.
+type Store struct {
+	filename    string
+	lock        sync.RWMutex
+	annotations map[int64]Annotation
+	nextID      int64
+}
+
+// Annotation is an annotation held by the Store.
+type Annotation struct {
+	ID      int64      `json:"id"`
+	Time    time.Time  `json:"time"`
+	TimeEnd *time.Time `json:"timeEnd,omitempty"` // end of an annotation region. nil for a single point in time
+	Title   string     `json:"title"`
+	Text    string     `json:"text,omitempty"`
+	Tags    []string   `json:"tags,omitempty"`
+}
+
+// New creates a Store that saves its annotations to the specified file. If the file exists, its annotations are loaded.
+func New(filename string) (*Store, error) {
+	s := Store{
+		filename:    filename,
+		annotations: make(map[int64]Annotation),
+		nextID:      1,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Store) load() error {
+	body, err := os.ReadFile(s.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", s.filename, err)
+	}
+	var annotations []Annotation
+	if err = json.Unmarshal(body, &annotations); err != nil {
+		return fmt.Errorf("decode %s: %w", s.filename, err)
+	}
+	for _, annotation := range annotations {
+		s.annotations[annotation.ID] = annotation
+		if annotation.ID >= s.nextID {
+			s.nextID = annotation.ID + 1
+		}
+	}
+	return nil
+}
+
+// save writes the annotations to the store's file. The file is replaced atomically, so a failed write doesn't corrupt it.
+// The caller must hold the lock.
+func (s *Store) save() error {
+	body, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.filename), filepath.Base(s.filename)+".*")
+	if err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+	_, err = f.Write(body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.filename)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("save: %w", err)
+	}
+	return nil
+}
+
+// Add stores the annotation and returns its ID. If the annotation has no Time, the current time is used.
+// If the annotation is not valid, Add returns an error wrapping ErrInvalid.
+func (s *Store) Add(annotation Annotation) (int64, error) {
+	if annotation.Time.IsZero() {
+		annotation.Time = time.Now()
+	}
+	if annotation.Title == "" {
+		return 0, fmt.Errorf("%w: missing title", ErrInvalid)
+	}
+	if annotation.TimeEnd != nil && annotation.TimeEnd.Before(annotation.Time) {
+		return 0, fmt.Errorf("%w: timeEnd is before time", ErrInvalid)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	annotation.ID = s.nextID
+	s.annotations[annotation.ID] = annotation
+	if err := s.save(); err != nil {
+		delete(s.annotations, annotation.ID)
+		return 0, err
+	}
+	s.nextID++
+	return annotation.ID, nil
+}
+
+// Delete removes the annotation with the specified ID. If the store has no such annotation, Delete returns ErrNotFound.
+func (s *Store) Delete(id int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	annotation, ok := s.annotations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.annotations, id)
+	if err := s.save(); err != nil {
+		s.annotations[id] = annotation
+		return err
+	}
+	return nil
+}
+
+// List returns all annotations, sorted by time.
+func (s *Store) List() []Annotation {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.list()
+}
+
+func (s *Store) list() []Annotation {
+	annotations := make([]Annotation, 0, len(s.annotations))
+	for _, annotation := range s.annotations {
+		annotations = append(annotations, annotation)
+	}
+	sort.Slice(annotations, func(i, j int) bool {
+		if annotations[i].Time.Equal(annotations[j].Time) {
+			return annotations[i].ID < annotations[j].ID
+		}
+		return annotations[i].Time.Before(annotations[j].Time)
+	})
+	return annotations
+}
+
+// Endpoints implements the simplejson.Handler interface.
+func (s *Store) Endpoints() simplejson.Endpoints {
+	return simplejson.Endpoints{AnnotationsContext: s.Annotations}
+}
+
+// Annotations returns the stored annotations that overlap with the request's time range (see simplejson.Annotation.InRange).
+func (s *Store) Annotations(_ context.Context, req simplejson.AnnotationRequest) ([]simplejson.Annotation, error) {
+	var annotations []simplejson.Annotation
+	for _, a := range s.List() {
+		annotation := simplejson.Annotation{
+			Time:  a.Time,
+			Title: a.Title,
+			Text:  a.Text,
+			Tags:  a.Tags,
+		}
+		if a.TimeEnd != nil {
+			annotation.TimeEnd = *a.TimeEnd
+		}
+		if annotation.InRange(req.Range) {
+			annotations = append(annotations, annotation)
+		}
+	}
+	return annotations, nil
+}
+
+// Authorizer determines if a request may change the Store. If it returns an error, the request is rejected with
+// HTTP status 401.
+type Authorizer func(req *http.Request) error
+
+// Handler returns an http.Handler to add and remove annotations. Mount it on the Server with the simplejson.WithHTTPHandler
+// option. Each request is first passed to authorize. If authorize is nil, all requests are allowed.
+// Request bodies larger than MaxRequestSize are rejected.
+func (s *Store) Handler(authorize Authorizer) http.Handler {
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if authorize != nil {
+				if err := authorize(req); err != nil {
+					http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+					return
+				}
+			}
+			req.Body = http.MaxBytesReader(w, req.Body, MaxRequestSize)
+			next.ServeHTTP(w, req)
+		})
+	})
+	r.Post("/", s.handleAdd)
+	r.Delete("/{id}", s.handleDelete)
+	return r
+}
+
+func (s *Store) handleAdd(w http.ResponseWriter, req *http.Request) {
+	var annotation Annotation
+	if err := json.NewDecoder(req.Body).Decode(&annotation); err != nil {
+		status := http.StatusBadRequest
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, "failed to parse request: "+err.Error(), status)
+		return
+	}
+	id, err := s.Add(annotation)
+	switch {
+	case errors.Is(err, ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "failed to add annotation: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(headers.ContentType, "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(struct {
+		ID int64 `json:"id"`
+	}{ID: id})
+}
+
+func (s *Store) handleDelete(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid annotation id: "+chi.URLParam(req, "id"), http.StatusBadRequest)
+		return
+	}
+	switch err = s.Delete(id); {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, "failed to delete annotation: "+err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
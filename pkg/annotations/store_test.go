@@ -0,0 +1,179 @@
+package annotations_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/clambin/simplejson/v6"
+	"github.com/clambin/simplejson/v6/pkg/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "annotations.json")
+	store, err := annotations.New(filename)
+	require.NoError(t, err)
+	assert.Empty(t, store.List())
+
+	timestamp := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	id1, err := store.Add(annotations.Annotation{Time: timestamp.Add(time.Hour), Title: "foo", Tags: []string{"A"}})
+	require.NoError(t, err)
+	id2, err := store.Add(annotations.Annotation{Time: timestamp, TimeEnd: pointer(timestamp.Add(time.Minute)), Title: "bar"})
+	require.NoError(t, err)
+	assert.NotEqual(t, id1, id2)
+
+	list := store.List()
+	require.Len(t, list, 2)
+	assert.Equal(t, "bar", list[0].Title)
+	assert.Equal(t, "foo", list[1].Title)
+
+	// annotations are persisted
+	store, err = annotations.New(filename)
+	require.NoError(t, err)
+	assert.Equal(t, list, store.List())
+
+	require.NoError(t, store.Delete(id2))
+	assert.ErrorIs(t, store.Delete(id2), annotations.ErrNotFound)
+
+	// new IDs don't reuse existing ones
+	id3, err := store.Add(annotations.Annotation{Title: "snafu"})
+	require.NoError(t, err)
+	assert.Greater(t, id3, id2)
+
+	store, err = annotations.New(filename)
+	require.NoError(t, err)
+	list = store.List()
+	require.Len(t, list, 2)
+	assert.Equal(t, "foo", list[0].Title)
+	assert.Equal(t, "snafu", list[1].Title)
+	assert.False(t, list[1].Time.IsZero())
+}
+
+func TestNew_Invalid(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "annotations.json")
+	require.NoError(t, os.WriteFile(filename, []byte("not json"), 0644))
+	_, err := annotations.New(filename)
+	assert.Error(t, err)
+}
+
+func TestStore_Annotations(t *testing.T) {
+	store, err := annotations.New(filepath.Join(t.TempDir(), "annotations.json"))
+	require.NoError(t, err)
+
+	day := func(d int) time.Time { return time.Date(2022, time.January, d, 0, 0, 0, 0, time.UTC) }
+	for _, annotation := range []annotations.Annotation{
+		{Time: day(1), Title: "before"},
+		{Time: day(2), TimeEnd: pointer(day(5)), Title: "region"},
+		{Time: day(6), Title: "inside", Tags: []string{"A", "B"}},
+		{Time: day(10), Title: "after"},
+	} {
+		_, err = store.Add(annotation)
+		require.NoError(t, err)
+	}
+
+	result, err := store.Annotations(context.Background(), simplejson.AnnotationRequest{Args: simplejson.Args{Range: simplejson.Range{From: day(4), To: day(8)}}})
+	require.NoError(t, err)
+	assert.Equal(t, []simplejson.Annotation{
+		{Time: day(2), TimeEnd: day(5), Title: "region"},
+		{Time: day(6), Title: "inside", Tags: []string{"A", "B"}},
+	}, result)
+}
+
+func TestStore_Handler(t *testing.T) {
+	store, err := annotations.New(filepath.Join(t.TempDir(), "annotations.json"))
+	require.NoError(t, err)
+	authorize := func(req *http.Request) error {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			return errors.New("invalid token")
+		}
+		return nil
+	}
+	s := simplejson.New(map[string]simplejson.Handler{"events": store},
+		simplejson.WithHTTPHandler{Path: "/annotations/store", Handler: store.Handler(authorize)},
+	)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	// add an annotation
+	w := do(http.MethodPost, "/annotations/store", `{"time":"2022-01-01T12:00:00Z","title":"deploy","tags":["prod"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// invalid requests
+	for _, tt := range []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "invalid json", body: `{"time":`, want: http.StatusBadRequest},
+		{name: "no title", body: `{"time":"2022-01-01T12:00:00Z"}`, want: http.StatusBadRequest},
+		{name: "ends before start", body: `{"time":"2022-01-01T12:00:00Z","timeEnd":"2022-01-01T11:00:00Z","title":"deploy"}`, want: http.StatusBadRequest},
+		{name: "too large", body: `{"title":"` + strings.Repeat("x", annotations.MaxRequestSize) + `"}`, want: http.StatusRequestEntityTooLarge},
+	} {
+		assert.Equal(t, tt.want, do(http.MethodPost, "/annotations/store", tt.body).Code, tt.name)
+	}
+
+	// unauthorized request
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/annotations/store", bytes.NewBufferString(`{"title":"deploy"}`))
+	s.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Len(t, store.List(), 1)
+
+	// the annotation is served by /annotations
+	query := func(from, to string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/annotations", bytes.NewBufferString(`{
+	"range": { "from": "`+from+`", "to": "`+to+`" },
+	"annotation": { "name": "events", "query": "events" }
+}`))
+		s.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	assert.Equal(t, `[{"annotation":{"name":"events","datasource":"","enable":false,"query":"events"},"time":1641038400000,"title":"deploy","text":"","tags":["prod"]}]
+`, query("2022-01-01T00:00:00Z", "2022-01-02T00:00:00Z"))
+	assert.Equal(t, "null\n", query("2022-01-02T00:00:00Z", "2022-01-03T00:00:00Z"))
+
+	// delete the annotation
+	for _, tt := range []struct {
+		id   string
+		want int
+	}{
+		{id: strconv.FormatInt(created.ID, 10), want: http.StatusNoContent},
+		{id: strconv.FormatInt(created.ID, 10), want: http.StatusNotFound},
+		{id: "foo", want: http.StatusBadRequest},
+	} {
+		assert.Equal(t, tt.want, do(http.MethodDelete, "/annotations/store/"+tt.id, "").Code, tt.id)
+	}
+	assert.Empty(t, store.List())
+}
+
+func TestAnnotation_MarshalJSON(t *testing.T) {
+	timestamp := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	body, err := json.Marshal(annotations.Annotation{ID: 1, Time: timestamp, Title: "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1,"time":"2022-01-01T00:00:00Z","title":"foo"}`, string(body))
+}
+
+func pointer[T any](value T) *T {
+	return &value
+}
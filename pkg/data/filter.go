@@ -2,26 +2,97 @@ package data
 
 import (
 	"github.com/clambin/simplejson/v6"
+	"strconv"
 	"time"
 )
 
-// Filter returns a Dataset meeting the provided query QueryArgs. Currently, it filters based on the args' time Range.
-// only the first time column is taken into consideration.
+// Filter returns a Dataset meeting the provided query QueryArgs. It filters based on the args' time Range and AdHocFilters.
+// For the time Range, only the first time column is taken into consideration.
+//
+// Ad hoc filters are applied to the table's string, number and boolean columns, using the column name as the filter's key.
+// As with AdHocFilters.Match, a filter whose key doesn't match one of those columns is evaluated against an empty value.
+// If the filters are invalid (e.g. an invalid regular expression), no rows are returned.
 func (t Table) Filter(args simplejson.Args) (filtered *Table) {
 	index, found := t.getFirstTimestampColumn()
 	if !found {
 		return &Table{Frame: t.Frame.EmptyCopy()}
 	}
 
-	f, _ := t.Frame.FilterRowsByField(index, func(i interface{}) (bool, error) {
-		if !args.Range.From.IsZero() && i.(time.Time).Before(args.Range.From) {
-			return false, nil
+	if len(args.AdHocFilters) == 0 {
+		f, _ := t.Frame.FilterRowsByField(index, func(i interface{}) (bool, error) {
+			return inRange(i.(time.Time), args.Range), nil
+		})
+		return &Table{Frame: f}
+	}
+
+	f := t.Frame.EmptyCopy()
+	filters, err := args.AdHocFilters.Compile()
+	if err != nil {
+		return &Table{Frame: f}
+	}
+	labels := make(map[string]string)
+	for row := 0; row < t.Frame.Rows(); row++ {
+		if !inRange(t.Frame.At(index, row).(time.Time), args.Range) {
+			continue
 		}
-		if !args.Range.To.IsZero() && i.(time.Time).After(args.Range.To) {
-			return false, nil
+		t.getLabels(row, labels)
+		if !filters.Match(labels) {
+			continue
 		}
-		return true, nil
-	})
-
+		f.AppendRow(t.Frame.RowCopy(row)...)
+	}
 	return &Table{Frame: f}
 }
+
+func inRange(timestamp time.Time, r simplejson.Range) bool {
+	if !r.From.IsZero() && timestamp.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && timestamp.After(r.To) {
+		return false
+	}
+	return true
+}
+
+// getLabels fills labels with the values of the table's string, number and boolean columns for the specified row.
+// Nil values of nullable columns are evaluated as an empty value.
+func (t Table) getLabels(row int, labels map[string]string) {
+	for _, f := range t.Frame.Fields {
+		delete(labels, f.Name)
+		switch value := deref(f.At(row)).(type) {
+		case string:
+			labels[f.Name] = value
+		case float64:
+			labels[f.Name] = strconv.FormatFloat(value, 'f', -1, 64)
+		case int64:
+			labels[f.Name] = strconv.FormatInt(value, 10)
+		case bool:
+			labels[f.Name] = strconv.FormatBool(value)
+		}
+	}
+}
+
+// deref returns the value that a nullable column's cell points to, or nil if the pointer is nil.
+func deref(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *string:
+		if v != nil {
+			return *v
+		}
+	case *float64:
+		if v != nil {
+			return *v
+		}
+	case *int64:
+		if v != nil {
+			return *v
+		}
+	case *bool:
+		if v != nil {
+			return *v
+		}
+	default:
+		return value
+	}
+	return nil
+}
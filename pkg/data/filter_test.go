@@ -25,6 +25,166 @@ func TestTable_FilterByTime(t *testing.T) {
 	}, output.GetTimestamps())
 }
 
+func TestTable_FilterByAdHocFilters(t *testing.T) {
+	d := createTable(10)
+
+	testCases := []struct {
+		name    string
+		filters simplejson.AdHocFilters
+		want    []time.Time
+	}{
+		{
+			name:    "string",
+			filters: simplejson.AdHocFilters{{Key: "labels", Operator: "=", Value: "5"}},
+			want:    []time.Time{time.Date(2022, time.June, 9, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "regex",
+			filters: simplejson.AdHocFilters{{Key: "labels", Operator: "=~", Value: "[3-4]"}},
+			want: []time.Time{
+				time.Date(2022, time.June, 7, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 8, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "number",
+			filters: simplejson.AdHocFilters{
+				{Key: "values", Operator: ">", Value: "1.5"},
+				{Key: "values", Operator: "<", Value: "3.5", Condition: "AND"},
+			},
+			want: []time.Time{
+				time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 7, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "outside of time range",
+			filters: simplejson.AdHocFilters{
+				{Key: "values", Operator: "=", Value: "0"},
+			},
+			want: nil,
+		},
+		{
+			name:    "unknown key is an empty value",
+			filters: simplejson.AdHocFilters{{Key: "host", Operator: "=", Value: "host-01"}},
+			want:    nil,
+		},
+		{
+			name:    "unknown key is an empty value - not equal",
+			filters: simplejson.AdHocFilters{{Key: "host", Operator: "!=", Value: "host-01"}},
+			want: []time.Time{
+				time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 7, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 8, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 9, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "unknown key in OR condition",
+			filters: simplejson.AdHocFilters{
+				{Key: "labels", Operator: "=", Value: "5"},
+				{Key: "zone", Operator: "!=", Value: "x", Condition: "OR"},
+			},
+			want: []time.Time{
+				time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 7, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 8, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 9, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:    "time column is not a label",
+			filters: simplejson.AdHocFilters{{Key: "time", Operator: "=", Value: ""}},
+			want: []time.Time{
+				time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 7, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 8, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 9, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:    "invalid filter",
+			filters: simplejson.AdHocFilters{{Key: "labels", Operator: "=~", Value: "("}},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			output := d.Filter(simplejson.Args{
+				Range: simplejson.Range{
+					From: time.Date(2022, 6, 5, 0, 0, 0, 0, time.UTC),
+					To:   time.Date(2022, 6, 9, 0, 0, 0, 0, time.UTC),
+				},
+				AdHocFilters: tt.filters,
+			})
+			assert.Equal(t, tt.want, output.GetTimestamps())
+			assert.Len(t, output.Frame.Fields, 4)
+		})
+	}
+}
+
+func TestTable_FilterByAdHocFilters_Types(t *testing.T) {
+	hostA, hostB := "a", "b"
+	load := 0.5
+	d := data.New(
+		data.Column{Name: "time", Values: []time.Time{
+			time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, time.June, 7, 0, 0, 0, 0, time.UTC),
+		}},
+		data.Column{Name: "count", Values: []int64{1, 2, 3}},
+		data.Column{Name: "up", Values: []bool{true, false, true}},
+		data.Column{Name: "host", Values: []*string{&hostA, nil, &hostB}},
+		data.Column{Name: "load", Values: []*float64{nil, &load, nil}},
+	)
+
+	testCases := []struct {
+		name    string
+		filters simplejson.AdHocFilters
+		want    []time.Time
+	}{
+		{
+			name:    "int64",
+			filters: simplejson.AdHocFilters{{Key: "count", Operator: ">", Value: "2"}},
+			want:    []time.Time{time.Date(2022, time.June, 7, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "bool",
+			filters: simplejson.AdHocFilters{{Key: "up", Operator: "=", Value: "false"}},
+			want:    []time.Time{time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "nullable string",
+			filters: simplejson.AdHocFilters{{Key: "host", Operator: "=", Value: "a"}},
+			want:    []time.Time{time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "nullable number",
+			filters: simplejson.AdHocFilters{{Key: "load", Operator: "=", Value: "0.5"}},
+			want:    []time.Time{time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "nil",
+			filters: simplejson.AdHocFilters{{Key: "host", Operator: "!=", Value: "b"}},
+			want: []time.Time{
+				time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			output := d.Filter(simplejson.Args{AdHocFilters: tt.filters})
+			assert.Equal(t, tt.want, output.GetTimestamps())
+		})
+	}
+}
+
 func TestTable_FilterByTime_Empty(t *testing.T) {
 	table := data.Table{Frame: grafanaData.NewFrame("bad")}
 
@@ -0,0 +1,181 @@
+package data
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// JoinType determines which timestamps are included in the output of a Join.
+type JoinType int
+
+const (
+	// OuterJoin includes the timestamps of all tables.
+	OuterJoin JoinType = iota
+	// InnerJoin only includes the timestamps that are present in all tables.
+	InnerJoin
+	// LeftJoin only includes the timestamps of the first table.
+	LeftJoin
+)
+
+// Joiner joins tables on their first time column.
+type Joiner struct {
+	// Type of the join. Default is OuterJoin.
+	Type JoinType
+	// Fill holds the value for cells that are missing in a table, by output column name. Numeric values are converted to the
+	// column's type (e.g. 0 for a float64 column). A value that can't be converted is ignored. If a column has no (valid) fill
+	// value, missing cells hold the zero value of the column's type.
+	Fill map[string]interface{}
+}
+
+// Join performs an outer join of the tables on their first time column. See Joiner for other types of joins.
+func Join(tables ...*Table) *Table {
+	return Joiner{}.Join(tables...)
+}
+
+// Join joins the tables on their first time column. The output table holds one time column, named after the first table's
+// time column, followed by the other columns of each table, in order. If a column name is already used by a previous table,
+// the column is renamed to "<name>_<n>", where n is the table's position in the list (starting from 1). If that name is
+// also used, n is increased until the name is unique.
+//
+// Tables without a time column are ignored. A table with an empty time column is joined as a table without rows, e.g. an
+// inner join with an empty table returns no rows. If a table holds the same timestamp more than once, the last row is used.
+// The output is sorted by time.
+func (j Joiner) Join(tables ...*Table) *Table {
+	var inputs []joinInput
+	for _, t := range tables {
+		if index, found := t.getFirstTimestampColumn(); found {
+			inputs = append(inputs, makeJoinInput(t, index))
+		}
+	}
+	if len(inputs) == 0 {
+		return &Table{Frame: data.NewFrame("frame")}
+	}
+
+	timestamps := j.getTimestamps(inputs)
+	fields := data.Fields{data.NewField(inputs[0].table.Frame.Fields[inputs[0].timeIndex].Name, nil, timestamps)}
+	names := map[string]struct{}{fields[0].Name: {}}
+
+	for n, input := range inputs {
+		for idx, f := range input.table.Frame.Fields {
+			if idx == input.timeIndex {
+				continue
+			}
+			name := f.Name
+			for suffix := n + 1; ; suffix++ {
+				if _, used := names[name]; !used {
+					break
+				}
+				name = f.Name + "_" + strconv.Itoa(suffix)
+			}
+			names[name] = struct{}{}
+
+			output := data.NewFieldFromFieldType(f.Type(), len(timestamps))
+			output.Name = name
+			fill, hasFill := convertFill(j.Fill[name], f.Type())
+			for row, timestamp := range timestamps {
+				if r, ok := input.rows[timestamp.UnixNano()]; ok {
+					output.Set(row, f.At(r))
+				} else if hasFill {
+					output.Set(row, fill)
+				}
+			}
+			fields = append(fields, output)
+		}
+	}
+
+	return &Table{Frame: data.NewFrame(inputs[0].table.Frame.Name, fields...)}
+}
+
+// convertFill converts a fill value to the specified field type. It returns false if the value can't be converted.
+func convertFill(value interface{}, fieldType data.FieldType) (interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	target := reflect.TypeOf(data.NewFieldFromFieldType(fieldType, 1).At(0))
+	nullable := target.Kind() == reflect.Pointer
+	if nullable {
+		target = target.Elem()
+	}
+
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Type() == target:
+	case isNumeric(v.Kind()) && isNumeric(target.Kind()):
+		v = v.Convert(target)
+	default:
+		return nil, false
+	}
+
+	if nullable {
+		p := reflect.New(target)
+		p.Elem().Set(v)
+		v = p
+	}
+	return v.Interface(), true
+}
+
+func isNumeric(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// joinInput is a table to be joined, with the row of each of its timestamps
+type joinInput struct {
+	table     *Table
+	timeIndex int
+	rows      map[int64]int
+}
+
+func makeJoinInput(t *Table, timeIndex int) joinInput {
+	input := joinInput{table: t, timeIndex: timeIndex, rows: make(map[int64]int)}
+	f := t.Frame.Fields[timeIndex]
+	for row := 0; row < f.Len(); row++ {
+		input.rows[f.At(row).(time.Time).UnixNano()] = row
+	}
+	return input
+}
+
+// getTimestamps returns the timestamps included in the output, sorted by time
+func (j Joiner) getTimestamps(inputs []joinInput) []time.Time {
+	timestamps := make(map[int64]time.Time)
+	for n, input := range inputs {
+		if n > 0 && j.Type == LeftJoin {
+			break
+		}
+		f := input.table.Frame.Fields[input.timeIndex]
+		for row := 0; row < f.Len(); row++ {
+			timestamp := f.At(row).(time.Time)
+			if _, ok := timestamps[timestamp.UnixNano()]; !ok {
+				timestamps[timestamp.UnixNano()] = timestamp
+			}
+		}
+	}
+
+	output := make([]time.Time, 0, len(timestamps))
+	for key, timestamp := range timestamps {
+		if j.Type == InnerJoin && !inAll(key, inputs) {
+			continue
+		}
+		output = append(output, timestamp)
+	}
+	sort.Slice(output, func(i, k int) bool { return output[i].Before(output[k]) })
+	return output
+}
+
+func inAll(key int64, inputs []joinInput) bool {
+	for _, input := range inputs {
+		if _, ok := input.rows[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
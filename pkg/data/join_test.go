@@ -0,0 +1,164 @@
+package data_test
+
+import (
+	"github.com/clambin/simplejson/v6"
+	"github.com/clambin/simplejson/v6/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestJoin(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2022, time.June, d, 0, 0, 0, 0, time.UTC) }
+
+	a := data.New(
+		data.Column{Name: "time", Values: []time.Time{day(1), day(2), day(3)}},
+		data.Column{Name: "value", Values: []float64{1, 2, 3}},
+		data.Column{Name: "label", Values: []string{"a1", "a2", "a3"}},
+	)
+	b := data.New(
+		data.Column{Name: "timestamp", Values: []time.Time{day(4), day(3), day(2)}},
+		data.Column{Name: "value", Values: []float64{40, 30, 20}},
+	)
+
+	testCases := []struct {
+		name       string
+		joiner     data.Joiner
+		timestamps []time.Time
+		values     []float64
+		values2    []float64
+		labels     []string
+	}{
+		{
+			name:       "outer",
+			joiner:     data.Joiner{},
+			timestamps: []time.Time{day(1), day(2), day(3), day(4)},
+			values:     []float64{1, 2, 3, 0},
+			values2:    []float64{0, 20, 30, 40},
+			labels:     []string{"a1", "a2", "a3", ""},
+		},
+		{
+			name:       "inner",
+			joiner:     data.Joiner{Type: data.InnerJoin},
+			timestamps: []time.Time{day(2), day(3)},
+			values:     []float64{2, 3},
+			values2:    []float64{20, 30},
+			labels:     []string{"a2", "a3"},
+		},
+		{
+			name:       "left",
+			joiner:     data.Joiner{Type: data.LeftJoin},
+			timestamps: []time.Time{day(1), day(2), day(3)},
+			values:     []float64{1, 2, 3},
+			values2:    []float64{0, 20, 30},
+			labels:     []string{"a1", "a2", "a3"},
+		},
+		{
+			name:       "fill",
+			joiner:     data.Joiner{Fill: map[string]interface{}{"value": -1.0, "value_2": -2.0, "label": "n/a"}},
+			timestamps: []time.Time{day(1), day(2), day(3), day(4)},
+			values:     []float64{1, 2, 3, -1},
+			values2:    []float64{-2, 20, 30, 40},
+			labels:     []string{"a1", "a2", "a3", "n/a"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			output := tt.joiner.Join(a, b)
+			assert.Equal(t, []string{"time", "value", "label", "value_2"}, output.GetColumns())
+			assert.Equal(t, tt.timestamps, output.GetTimestamps())
+			values, ok := output.GetFloatValues("value")
+			require.True(t, ok)
+			assert.Equal(t, tt.values, values)
+			values, ok = output.GetFloatValues("value_2")
+			require.True(t, ok)
+			assert.Equal(t, tt.values2, values)
+			labels, ok := output.GetStringValues("label")
+			require.True(t, ok)
+			assert.Equal(t, tt.labels, labels)
+
+			_, err := output.CreateTableResponse().MarshalJSON()
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestJoin_Default(t *testing.T) {
+	output := data.Join(createTable(5), createTable(10))
+	assert.Equal(t, []string{"time", "values", "", "labels", "values_2", "_2", "labels_2"}, output.GetColumns())
+	assert.Len(t, output.GetTimestamps(), 10)
+
+	response := output.CreateTableResponse()
+	require.Len(t, response.Columns, 7)
+	assert.Equal(t, simplejson.NumberColumn{0, 1, 2, 3, 4, 0, 0, 0, 0, 0}, response.Columns[1].Data)
+}
+
+func TestJoin_Collision(t *testing.T) {
+	timestamps := []time.Time{time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)}
+	a := data.New(
+		data.Column{Name: "time", Values: timestamps},
+		data.Column{Name: "value", Values: []float64{1}},
+		data.Column{Name: "value_2", Values: []float64{2}},
+	)
+	b := data.New(
+		data.Column{Name: "time", Values: timestamps},
+		data.Column{Name: "value", Values: []float64{3}},
+	)
+
+	output := data.Join(a, b)
+	assert.Equal(t, []string{"time", "value", "value_2", "value_3"}, output.GetColumns())
+	values, ok := output.GetFloatValues("value_3")
+	require.True(t, ok)
+	assert.Equal(t, []float64{3}, values)
+}
+
+func TestJoin_Fill(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2022, time.June, d, 0, 0, 0, 0, time.UTC) }
+	a := data.New(
+		data.Column{Name: "time", Values: []time.Time{day(1), day(2)}},
+		data.Column{Name: "value", Values: []float64{1, 2}},
+		data.Column{Name: "count", Values: []int64{1, 2}},
+		data.Column{Name: "label", Values: []string{"a", "b"}},
+	)
+	b := data.New(data.Column{Name: "time", Values: []time.Time{day(3)}})
+
+	output := data.Joiner{Fill: map[string]interface{}{"value": 0, "count": 1.0, "label": 5}}.Join(a, b)
+	response := output.CreateTableResponse()
+	require.Len(t, response.Columns, 4)
+	assert.Equal(t, simplejson.NumberColumn{1, 2, 0}, response.Columns[1].Data)
+	assert.Equal(t, simplejson.IntColumn{1, 2, 1}, response.Columns[2].Data)
+	// a fill value that can't be converted is ignored
+	assert.Equal(t, simplejson.StringColumn{"a", "b", ""}, response.Columns[3].Data)
+}
+
+func TestJoin_EmptyTimeColumn(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2022, time.June, d, 0, 0, 0, 0, time.UTC) }
+	a := data.New(
+		data.Column{Name: "time", Values: []time.Time{day(1), day(2)}},
+		data.Column{Name: "value", Values: []float64{1, 2}},
+	)
+	b := data.New(
+		data.Column{Name: "time", Values: []time.Time{}},
+		data.Column{Name: "value", Values: []float64{}},
+	)
+
+	output := data.Joiner{Type: data.InnerJoin}.Join(a, b)
+	assert.Equal(t, []string{"time", "value", "value_2"}, output.GetColumns())
+	assert.Empty(t, output.GetTimestamps())
+
+	output = data.Join(a, b)
+	assert.Equal(t, []time.Time{day(1), day(2)}, output.GetTimestamps())
+	values, ok := output.GetFloatValues("value_2")
+	require.True(t, ok)
+	assert.Equal(t, []float64{0, 0}, values)
+}
+
+func TestJoin_Empty(t *testing.T) {
+	output := data.Join()
+	assert.Empty(t, output.GetColumns())
+
+	output = data.Join(data.New(data.Column{Name: "value", Values: []float64{1, 2}}))
+	assert.Empty(t, output.GetColumns())
+}
@@ -0,0 +1,190 @@
+package data
+
+import (
+	"sort"
+	"time"
+)
+
+// Interval determines how Resample groups timestamps into buckets.
+type Interval interface {
+	// Truncate returns the start of the bucket that holds the timestamp.
+	Truncate(timestamp time.Time) time.Time
+}
+
+// Every returns an Interval of fixed duration, aligned to the wall clock of the specified location. If location is nil, UTC is used.
+// E.g. Every(time.Hour, location) creates buckets that start at the top of each hour in that location.
+func Every(duration time.Duration, location *time.Location) Interval {
+	return fixedInterval{duration: duration, location: getLocation(location)}
+}
+
+// Day returns an Interval that creates a bucket per calendar day in the specified location. If location is nil, UTC is used.
+func Day(location *time.Location) Interval {
+	return calendarInterval{days: 1, location: getLocation(location)}
+}
+
+// Week returns an Interval that creates a bucket per calendar week, starting on Monday, in the specified location.
+// If location is nil, UTC is used.
+func Week(location *time.Location) Interval {
+	return calendarInterval{days: 7, location: getLocation(location)}
+}
+
+// Month returns an Interval that creates a bucket per calendar month in the specified location. If location is nil, UTC is used.
+func Month(location *time.Location) Interval {
+	return calendarInterval{months: 1, location: getLocation(location)}
+}
+
+func getLocation(location *time.Location) *time.Location {
+	if location == nil {
+		return time.UTC
+	}
+	return location
+}
+
+type fixedInterval struct {
+	duration time.Duration
+	location *time.Location
+}
+
+func (i fixedInterval) Truncate(timestamp time.Time) time.Time {
+	timestamp = timestamp.In(i.location)
+	_, offset := timestamp.Zone()
+	shift := time.Duration(offset) * time.Second
+	return timestamp.Add(shift).Truncate(i.duration).Add(-shift)
+}
+
+type calendarInterval struct {
+	days     int
+	months   int
+	location *time.Location
+}
+
+func (i calendarInterval) Truncate(timestamp time.Time) time.Time {
+	timestamp = timestamp.In(i.location)
+	year, month, day := timestamp.Date()
+	switch {
+	case i.months > 0:
+		return time.Date(year, month, 1, 0, 0, 0, 0, i.location)
+	case i.days == 7:
+		weekday := (int(timestamp.Weekday()) + 6) % 7 // Monday is the first day of the week
+		return time.Date(year, month, day-weekday, 0, 0, 0, 0, i.location)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, i.location)
+	}
+}
+
+// Aggregator combines the number values of one column in a bucket into a single value.
+type Aggregator func(values []float64) float64
+
+// Sum returns the sum of the values.
+func Sum(values []float64) (total float64) {
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
+
+// Avg returns the average of the values.
+func Avg(values []float64) float64 {
+	return Sum(values) / float64(len(values))
+}
+
+// Min returns the lowest value.
+func Min(values []float64) float64 {
+	low := values[0]
+	for _, value := range values[1:] {
+		if value < low {
+			low = value
+		}
+	}
+	return low
+}
+
+// Max returns the highest value.
+func Max(values []float64) float64 {
+	high := values[0]
+	for _, value := range values[1:] {
+		if value > high {
+			high = value
+		}
+	}
+	return high
+}
+
+// Last returns the last value.
+func Last(values []float64) float64 {
+	return values[len(values)-1]
+}
+
+// Count returns the number of values.
+func Count(values []float64) float64 {
+	return float64(len(values))
+}
+
+// Aggregators selects the Aggregator for each number column of a Table. Columns maps a column name to its Aggregator.
+// Columns that are not listed use Default. If Default is nil, unlisted columns keep the last value of each bucket.
+type Aggregators struct {
+	Default Aggregator
+	Columns map[string]Aggregator
+}
+
+func (a Aggregators) get(column string) Aggregator {
+	if aggregator, ok := a.Columns[column]; ok {
+		return aggregator
+	}
+	if a.Default != nil {
+		return a.Default
+	}
+	return Last
+}
+
+// Resample creates a new Table where the rows are grouped into buckets by the first time column, using the specified Interval.
+// The time column holds the start of each bucket. The values of each number column (float64 or int64, or their nullable
+// variants) are combined with that column's Aggregator. Nil values are ignored: if a bucket only holds nil values, its value
+// is nil. For int64 columns, the result is rounded to the nearest integer. For all other columns, the last value of the
+// bucket is kept. The output is sorted by time.
+func (t Table) Resample(interval Interval, aggregators Aggregators) *Table {
+	index, found := t.getFirstTimestampColumn()
+	if !found {
+		return &Table{Frame: t.Frame.EmptyCopy()}
+	}
+
+	// group the rows by bucket
+	buckets := make(map[time.Time][]int)
+	var starts []time.Time
+	for row := 0; row < t.Frame.Rows(); row++ {
+		start := interval.Truncate(t.Frame.At(index, row).(time.Time))
+		if _, ok := buckets[start]; !ok {
+			starts = append(starts, start)
+		}
+		buckets[start] = append(buckets[start], row)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	output := t.Frame.EmptyCopy()
+	for idx, f := range t.Frame.Fields {
+		output.Fields[idx].Extend(len(starts))
+		for row, start := range starts {
+			rows := buckets[start]
+			switch {
+			case idx == index:
+				output.Fields[idx].Set(row, start)
+			case isNumber(f):
+				values := make([]float64, 0, len(rows))
+				for _, r := range rows {
+					if value, ok := getNumber(f, r); ok {
+						values = append(values, value)
+					}
+				}
+				if len(values) == 0 {
+					setNumber(output.Fields[idx], row, 0, false)
+					continue
+				}
+				setNumber(output.Fields[idx], row, aggregators.get(f.Name)(values), true)
+			default:
+				output.Fields[idx].Set(row, f.At(rows[len(rows)-1]))
+			}
+		}
+	}
+
+	return &Table{Frame: output}
+}
@@ -0,0 +1,199 @@
+package data_test
+
+import (
+	"github.com/clambin/simplejson/v6/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTable_Resample(t *testing.T) {
+	input := createTable(10)
+
+	testCases := []struct {
+		name       string
+		interval   data.Interval
+		aggregator data.Aggregators
+		timestamps []time.Time
+		values     []float64
+		others     []float64
+		labels     []string
+	}{
+		{
+			name:       "fixed",
+			interval:   data.Every(72*time.Hour, nil),
+			aggregator: data.Aggregators{Default: data.Sum},
+			timestamps: []time.Time{
+				time.Date(2022, time.June, 4, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 7, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 10, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 13, 0, 0, 0, 0, time.UTC),
+			},
+			values: []float64{3, 12, 21, 9},
+			others: []float64{3, 12, 21, 9},
+			labels: []string{"2", "5", "8", "9"},
+		},
+		{
+			name:       "week",
+			interval:   data.Week(nil),
+			aggregator: data.Aggregators{Default: data.Avg},
+			timestamps: []time.Time{
+				time.Date(2022, time.May, 30, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 13, 0, 0, 0, 0, time.UTC),
+			},
+			values: []float64{0.5, 5, 9},
+			others: []float64{0.5, 5, 9},
+			labels: []string{"1", "8", "9"},
+		},
+		{
+			name:       "month",
+			interval:   data.Month(nil),
+			aggregator: data.Aggregators{Default: data.Count},
+			timestamps: []time.Time{time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)},
+			values:     []float64{10},
+			others:     []float64{10},
+			labels:     []string{"9"},
+		},
+		{
+			name:       "day",
+			interval:   data.Day(nil),
+			aggregator: data.Aggregators{Default: data.Max},
+			timestamps: input.GetTimestamps(),
+			values:     []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+			others:     []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+			labels:     []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"},
+		},
+		{
+			name:     "per column",
+			interval: data.Every(72*time.Hour, nil),
+			aggregator: data.Aggregators{
+				Default: data.Sum,
+				Columns: map[string]data.Aggregator{"values": data.Max},
+			},
+			timestamps: []time.Time{
+				time.Date(2022, time.June, 4, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 7, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 10, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, time.June, 13, 0, 0, 0, 0, time.UTC),
+			},
+			values: []float64{2, 5, 8, 9},
+			others: []float64{3, 12, 21, 9},
+			labels: []string{"2", "5", "8", "9"},
+		},
+		{
+			name:       "no default",
+			interval:   data.Month(nil),
+			aggregator: data.Aggregators{Columns: map[string]data.Aggregator{"values": data.Count}},
+			timestamps: []time.Time{time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)},
+			values:     []float64{10},
+			others:     []float64{9},
+			labels:     []string{"9"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			output := input.Resample(tt.interval, tt.aggregator)
+			assert.Equal(t, tt.timestamps, output.GetTimestamps())
+			values, ok := output.GetFloatValues("values")
+			require.True(t, ok)
+			assert.Equal(t, tt.values, values)
+			others, ok := output.GetFloatValues("")
+			require.True(t, ok)
+			assert.Equal(t, tt.others, others)
+			labels, ok := output.GetStringValues("labels")
+			require.True(t, ok)
+			assert.Equal(t, tt.labels, labels)
+		})
+	}
+}
+
+func TestTable_Resample_Location(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Brussels")
+	require.NoError(t, err)
+
+	// 23:30 UTC on June 4th is already June 5th in Brussels
+	input := data.New(
+		data.Column{Name: "time", Values: []time.Time{
+			time.Date(2022, time.June, 4, 21, 30, 0, 0, time.UTC),
+			time.Date(2022, time.June, 4, 23, 30, 0, 0, time.UTC),
+			time.Date(2022, time.June, 5, 10, 0, 0, 0, time.UTC),
+		}},
+		data.Column{Name: "values", Values: []float64{1, 2, 3}},
+	)
+
+	output := input.Resample(data.Day(location), data.Aggregators{Default: data.Sum})
+	timestamps := output.GetTimestamps()
+	require.Len(t, timestamps, 2)
+	assert.True(t, time.Date(2022, time.June, 4, 0, 0, 0, 0, location).Equal(timestamps[0]))
+	assert.True(t, time.Date(2022, time.June, 5, 0, 0, 0, 0, location).Equal(timestamps[1]))
+	values, _ := output.GetFloatValues("values")
+	assert.Equal(t, []float64{1, 5}, values)
+
+	output = input.Resample(data.Every(time.Hour, location), data.Aggregators{Default: data.Min})
+	timestamps = output.GetTimestamps()
+	require.Len(t, timestamps, 3)
+	assert.True(t, time.Date(2022, time.June, 4, 23, 0, 0, 0, location).Equal(timestamps[0]))
+}
+
+func TestTable_Resample_Unsorted(t *testing.T) {
+	input := data.New(
+		data.Column{Name: "time", Values: []time.Time{
+			time.Date(2022, time.June, 5, 12, 0, 0, 0, time.UTC),
+			time.Date(2022, time.June, 4, 12, 0, 0, 0, time.UTC),
+			time.Date(2022, time.June, 5, 18, 0, 0, 0, time.UTC),
+		}},
+		data.Column{Name: "values", Values: []float64{1, 2, 3}},
+	)
+
+	output := input.Resample(data.Day(nil), data.Aggregators{})
+	assert.Equal(t, []time.Time{
+		time.Date(2022, time.June, 4, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC),
+	}, output.GetTimestamps())
+	values, _ := output.GetFloatValues("values")
+	assert.Equal(t, []float64{2, 3}, values)
+}
+
+func TestTable_Resample_Types(t *testing.T) {
+	value := 1.5
+	input := data.New(
+		data.Column{Name: "time", Values: []time.Time{
+			time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, time.June, 5, 12, 0, 0, 0, time.UTC),
+			time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC),
+		}},
+		data.Column{Name: "int", Values: []int64{1, 2, 3}},
+		data.Column{Name: "nullable", Values: []*float64{&value, &value, nil}},
+	)
+
+	output := input.Resample(data.Day(nil), data.Aggregators{Default: data.Sum})
+	require.Equal(t, []time.Time{
+		time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, time.June, 6, 0, 0, 0, 0, time.UTC),
+	}, output.GetTimestamps())
+
+	values, _ := output.GetValues("int")
+	assert.Equal(t, []interface{}{int64(3), int64(3)}, values)
+	values, _ = output.GetValues("nullable")
+	require.Len(t, values, 2)
+	require.NotNil(t, values[0])
+	assert.Equal(t, 3.0, *values[0].(*float64))
+	assert.Nil(t, values[1])
+
+	output = input.Resample(data.Day(nil), data.Aggregators{Default: data.Avg})
+	values, _ = output.GetValues("int")
+	assert.Equal(t, []interface{}{int64(2), int64(3)}, values)
+}
+
+func TestAggregators(t *testing.T) {
+	values := []float64{3, 1, 4, 1, 5}
+	assert.Equal(t, 14.0, data.Sum(values))
+	assert.Equal(t, 2.8, data.Avg(values))
+	assert.Equal(t, 1.0, data.Min(values))
+	assert.Equal(t, 5.0, data.Max(values))
+	assert.Equal(t, 5.0, data.Last(values))
+	assert.Equal(t, 5.0, data.Count(values))
+}
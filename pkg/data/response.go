@@ -3,10 +3,12 @@ package data
 import (
 	"github.com/clambin/simplejson/v6"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"math"
 	"time"
 )
 
-// CreateTableResponse creates a simplejson TableResponse from a Dataset
+// CreateTableResponse creates a simplejson TableResponse from a Dataset. Time, string, float64, int64 and bool columns,
+// and their nullable (pointer) variants, are supported. Columns of other types have no data and fail to marshal.
 func (t Table) CreateTableResponse() *simplejson.TableResponse {
 	columns := make([]simplejson.Column, len(t.Frame.Fields))
 
@@ -24,18 +26,104 @@ func makeColumn(f *data.Field) (column simplejson.Column) {
 	}
 
 	var values interface{}
-	if f.Len() > 0 {
-		switch f.At(0).(type) {
-		case time.Time:
-			values = simplejson.TimeColumn(getFieldValues[time.Time](f))
-		case string:
-			values = simplejson.StringColumn(getFieldValues[string](f))
-		case float64:
-			values = simplejson.NumberColumn(getFieldValues[float64](f))
-		}
+	switch f.Type() {
+	case data.FieldTypeTime:
+		values = simplejson.TimeColumn(getFieldValues[time.Time](f))
+	case data.FieldTypeNullableTime:
+		values = simplejson.NullableTimeColumn(getFieldValues[*time.Time](f))
+	case data.FieldTypeString:
+		values = simplejson.StringColumn(getFieldValues[string](f))
+	case data.FieldTypeNullableString:
+		values = simplejson.NullableStringColumn(getFieldValues[*string](f))
+	case data.FieldTypeFloat64:
+		values = simplejson.NumberColumn(getFieldValues[float64](f))
+	case data.FieldTypeNullableFloat64:
+		values = simplejson.NullableNumberColumn(getFieldValues[*float64](f))
+	case data.FieldTypeInt64:
+		values = simplejson.IntColumn(getFieldValues[int64](f))
+	case data.FieldTypeNullableInt64:
+		values = simplejson.NullableIntColumn(getFieldValues[*int64](f))
+	case data.FieldTypeBool:
+		values = simplejson.BoolColumn(getFieldValues[bool](f))
+	case data.FieldTypeNullableBool:
+		values = simplejson.NullableBoolColumn(getFieldValues[*bool](f))
 	}
 	return simplejson.Column{
 		Text: name,
 		Data: values,
 	}
 }
+
+// CreateTimeSeriesResponses creates a simplejson TimeSeriesResponse for each number column (float64 or int64, or their
+// nullable variants) of the Table, using the first time column for the data points' timestamps. The response's Target is
+// the column's name. Nil values are returned as NaN, which is sent to Grafana as null.
+func (t Table) CreateTimeSeriesResponses() (responses []simplejson.TimeSeriesResponse) {
+	index, found := t.getFirstTimestampColumn()
+	if !found {
+		return nil
+	}
+
+	timestamps := getFieldValues[time.Time](t.Frame.Fields[index])
+	for _, f := range t.Frame.Fields {
+		if !isNumber(f) {
+			continue
+		}
+		name := f.Name
+		if name == "" {
+			name = "(unknown)"
+		}
+		dataPoints := make([]simplejson.DataPoint, len(timestamps))
+		for row, timestamp := range timestamps {
+			value, ok := getNumber(f, row)
+			if !ok {
+				value = math.NaN()
+			}
+			dataPoints[row] = simplejson.DataPoint{Timestamp: timestamp, Value: value}
+		}
+		responses = append(responses, simplejson.TimeSeriesResponse{Target: name, DataPoints: dataPoints})
+	}
+	return responses
+}
+
+// FromTimeSeries creates a Table from one or more simplejson TimeSeriesResponses. The Table has a "time" column, holding the
+// timestamps of all responses, followed by a number column for each response, named after the response's Target.
+// If a response has no data point for a timestamp, its value is NaN, which is sent to Grafana as null. Responses without
+// data points are ignored. Use Joiner to combine the responses differently.
+func FromTimeSeries(responses ...simplejson.TimeSeriesResponse) *Table {
+	var tables []*Table
+	for _, response := range responses {
+		if len(response.DataPoints) > 0 {
+			tables = append(tables, fromTimeSeries(response))
+		}
+	}
+	output := Join(tables...)
+
+	// Join fills missing values with zero. Mark them as missing instead.
+	timestamps := output.GetTimestamps()
+	for i, table := range tables {
+		present := make(map[int64]struct{}, table.Frame.Rows())
+		for _, timestamp := range table.GetTimestamps() {
+			present[timestamp.UnixNano()] = struct{}{}
+		}
+		f := output.Frame.Fields[i+1]
+		for row, timestamp := range timestamps {
+			if _, ok := present[timestamp.UnixNano()]; !ok {
+				f.Set(row, math.NaN())
+			}
+		}
+	}
+	return output
+}
+
+func fromTimeSeries(response simplejson.TimeSeriesResponse) *Table {
+	timestamps := make([]time.Time, len(response.DataPoints))
+	values := make([]float64, len(response.DataPoints))
+	for i, d := range response.DataPoints {
+		timestamps[i] = d.Timestamp
+		values[i] = d.Value
+	}
+	return New(
+		Column{Name: "time", Values: timestamps},
+		Column{Name: response.Target, Values: values},
+	)
+}
@@ -5,11 +5,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"flag"
+	"github.com/clambin/simplejson/v6"
+	"github.com/clambin/simplejson/v6/pkg/data"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 var update = flag.Bool("update", false, "update .golden files")
@@ -37,3 +41,117 @@ func TestTable_CreateTableResponse(t *testing.T) {
 
 	assert.Equal(t, string(golden), b.String())
 }
+
+func TestTable_CreateTimeSeriesResponses(t *testing.T) {
+	table := createTable(3)
+	responses := table.CreateTimeSeriesResponses()
+	require.Len(t, responses, 2)
+	assert.Equal(t, "values", responses[0].Target)
+	assert.Equal(t, "(unknown)", responses[1].Target)
+
+	timestamps := table.GetTimestamps()
+	for _, response := range responses {
+		require.Len(t, response.DataPoints, 3)
+		for i, d := range response.DataPoints {
+			assert.Equal(t, timestamps[i], d.Timestamp)
+			assert.Equal(t, float64(i), d.Value)
+		}
+	}
+
+	assert.Empty(t, data.New(data.Column{Name: "value", Values: []float64{1, 2}}).CreateTimeSeriesResponses())
+}
+
+func TestTable_CreateTimeSeriesResponses_Types(t *testing.T) {
+	value := 1.5
+	table := data.New(
+		data.Column{Name: "time", Values: []time.Time{
+			time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, time.June, 2, 0, 0, 0, 0, time.UTC),
+		}},
+		data.Column{Name: "int", Values: []int64{1, 2}},
+		data.Column{Name: "nullable", Values: []*float64{&value, nil}},
+		data.Column{Name: "label", Values: []string{"a", "b"}},
+	)
+
+	responses := table.CreateTimeSeriesResponses()
+	require.Len(t, responses, 2)
+	assert.Equal(t, "int", responses[0].Target)
+	require.Len(t, responses[0].DataPoints, 2)
+	assert.Equal(t, 1.0, responses[0].DataPoints[0].Value)
+	assert.Equal(t, 2.0, responses[0].DataPoints[1].Value)
+	assert.Equal(t, "nullable", responses[1].Target)
+	require.Len(t, responses[1].DataPoints, 2)
+	assert.Equal(t, 1.5, responses[1].DataPoints[0].Value)
+	assert.True(t, math.IsNaN(responses[1].DataPoints[1].Value))
+}
+
+func TestFromTimeSeries(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2022, time.June, d, 0, 0, 0, 0, time.UTC) }
+
+	table := data.FromTimeSeries(
+		simplejson.TimeSeriesResponse{Target: "A", DataPoints: []simplejson.DataPoint{
+			{Timestamp: day(1), Value: 1},
+			{Timestamp: day(2), Value: 2},
+		}},
+		simplejson.TimeSeriesResponse{Target: "B", DataPoints: []simplejson.DataPoint{
+			{Timestamp: day(2), Value: 20},
+			{Timestamp: day(3), Value: 30},
+		}},
+	)
+
+	assert.Equal(t, []string{"time", "A", "B"}, table.GetColumns())
+	assert.Equal(t, []time.Time{day(1), day(2), day(3)}, table.GetTimestamps())
+	values, ok := table.GetFloatValues("A")
+	require.True(t, ok)
+	require.Len(t, values, 3)
+	assert.Equal(t, []float64{1, 2}, values[:2])
+	assert.True(t, math.IsNaN(values[2]))
+	values, ok = table.GetFloatValues("B")
+	require.True(t, ok)
+	require.Len(t, values, 3)
+	assert.True(t, math.IsNaN(values[0]))
+	assert.Equal(t, []float64{20, 30}, values[1:])
+
+	responses := table.CreateTimeSeriesResponses()
+	require.Len(t, responses, 2)
+	assert.Equal(t, "A", responses[0].Target)
+	assert.Len(t, responses[0].DataPoints, 3)
+
+	// missing values are sent as null
+	body, err := responses[0].MarshalJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"target":"A","datapoints":[[1,1654041600000],[2,1654128000000],[null,1654214400000]]}`, string(body))
+	body, err = table.CreateTableResponse().MarshalJSON()
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"rows":[["2022-06-01T00:00:00Z",1,null],`)
+}
+
+func TestFromTimeSeries_Empty(t *testing.T) {
+	table := data.FromTimeSeries(
+		simplejson.TimeSeriesResponse{Target: "A"},
+		simplejson.TimeSeriesResponse{Target: "B", DataPoints: []simplejson.DataPoint{{Timestamp: time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC), Value: 1}}},
+	)
+	assert.Equal(t, []string{"time", "B"}, table.GetColumns())
+}
+
+func TestTable_CreateTableResponse_Types(t *testing.T) {
+	value := 1.0
+	table := data.New(
+		data.Column{Name: "time", Values: []time.Time{time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)}},
+		data.Column{Name: "int", Values: []int64{1}},
+		data.Column{Name: "bool", Values: []bool{true}},
+		data.Column{Name: "nullable", Values: []*float64{&value}},
+		data.Column{Name: "null", Values: []*string{nil}},
+	)
+
+	response := table.CreateTableResponse()
+	require.Len(t, response.Columns, 5)
+	assert.Equal(t, simplejson.IntColumn{1}, response.Columns[1].Data)
+	assert.Equal(t, simplejson.BoolColumn{true}, response.Columns[2].Data)
+	assert.Equal(t, simplejson.NullableNumberColumn{&value}, response.Columns[3].Data)
+	assert.Equal(t, simplejson.NullableStringColumn{nil}, response.Columns[4].Data)
+
+	output, err := response.MarshalJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"table","columns":[{"text":"time","type":"time"},{"text":"int","type":"number"},{"text":"bool","type":"boolean"},{"text":"nullable","type":"number"},{"text":"null","type":"string"}],"rows":[["2022-06-01T00:00:00Z",1,true,1,null]]}`, string(output))
+}
@@ -3,6 +3,7 @@ package data
 import (
 	"github.com/clambin/go-common/set"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"math"
 	"time"
 )
 
@@ -41,10 +42,8 @@ func (t Table) GetTimestamps() (timestamps []time.Time) {
 
 func (t Table) getFirstTimestampColumn() (index int, found bool) {
 	for i, f := range t.Frame.Fields {
-		if f.Len() > 0 {
-			if _, ok := f.At(0).(time.Time); ok {
-				return i, true
-			}
+		if f.Type() == data.FieldTypeTime {
+			return i, true
 		}
 	}
 	return
@@ -107,6 +106,58 @@ func getFieldValues[T any](f *data.Field) (values []T) {
 	return
 }
 
+// isNumber reports whether the field holds float64 or int64 values, or their nullable variants.
+func isNumber(f *data.Field) bool {
+	switch f.Type() {
+	case data.FieldTypeFloat64, data.FieldTypeNullableFloat64, data.FieldTypeInt64, data.FieldTypeNullableInt64:
+		return true
+	}
+	return false
+}
+
+// getNumber returns the value of a number field as a float64. ok is false if the value is nil.
+func getNumber(f *data.Field, row int) (value float64, ok bool) {
+	switch v := f.At(row).(type) {
+	case float64:
+		return v, true
+	case *float64:
+		if v != nil {
+			return *v, true
+		}
+	case int64:
+		return float64(v), true
+	case *int64:
+		if v != nil {
+			return float64(*v), true
+		}
+	}
+	return 0, false
+}
+
+// setNumber sets the value of a number field. int64 fields receive the value rounded to the nearest integer.
+// If ok is false, a nullable field receives nil.
+func setNumber(f *data.Field, row int, value float64, ok bool) {
+	switch f.Type() {
+	case data.FieldTypeFloat64:
+		f.Set(row, value)
+	case data.FieldTypeNullableFloat64:
+		if ok {
+			f.Set(row, &value)
+		} else {
+			f.Set(row, (*float64)(nil))
+		}
+	case data.FieldTypeInt64:
+		f.Set(row, int64(math.Round(value)))
+	case data.FieldTypeNullableInt64:
+		if ok {
+			v := int64(math.Round(value))
+			f.Set(row, &v)
+		} else {
+			f.Set(row, (*int64)(nil))
+		}
+	}
+}
+
 // DeleteColumn returns a table with the listed columns removed
 func (t Table) DeleteColumn(columns ...string) *Table {
 	fields := make([]*data.Field, 0, len(t.Frame.Fields))
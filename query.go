@@ -2,9 +2,11 @@ package simplejson
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
 	"github.com/mailru/easyjson"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,12 +19,64 @@ type QueryRequest struct {
 	QueryArgs
 }
 
-// Target specifies the requested target name and type.
+// Target specifies the requested target name and type, and any additional query data that Grafana sends for the target.
 //
 //easyjson:skip
 type Target struct {
-	Name string `json:"target"` // name of the target.
-	Type string `json:"type"`   // "timeserie" or "" for timeseries. "table" for table queries.
+	Name       string          `json:"target"`     // name of the target.
+	Type       string          `json:"type"`       // "timeserie" or "" for timeseries. "table" for table queries.
+	RefID      string          `json:"refId"`      // Grafana's reference ID for the target (e.g. "A").
+	Hide       bool            `json:"hide"`       // true if the target is hidden in the panel.
+	Datasource Datasource      `json:"datasource"` // datasource that sent the target.
+	Data       json.RawMessage `json:"data"`       // additional JSON data entered in the query editor of the SimpleJSON datasource.
+	Payload    json.RawMessage `json:"payload"`    // payload options selected in the query editor of simPod's JSON datasource.
+}
+
+// DecodePayload decodes the target's query data into v. It decodes the Payload sent by simPod's JSON datasource or,
+// if there is no payload, the Data sent by the SimpleJSON datasource. If the target has neither, v is left unchanged.
+func (t Target) DecodePayload(v interface{}) error {
+	raw := t.Payload
+	if len(raw) == 0 || string(raw) == "null" {
+		raw = t.Data
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	// some datasource versions send the payload as a string holding JSON
+	if raw[0] == '"' {
+		var payload string
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return err
+		}
+		if payload == "" {
+			return nil
+		}
+		raw = json.RawMessage(payload)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// Datasource identifies a Grafana datasource. Older versions of Grafana only send the datasource's name.
+//
+//easyjson:skip
+type Datasource struct {
+	Name string `json:"-"`
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// UnmarshalJSON unmarshalls a Datasource from JSON. It accepts both a datasource object and a datasource name.
+func (d *Datasource) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &d.Name)
+	}
+	type datasource2 Datasource
+	var c datasource2
+	err := json.Unmarshal(b, &c)
+	if err == nil {
+		*d = Datasource(c)
+	}
+	return err
 }
 
 // QueryArgs contains the arguments for a Query.
@@ -30,7 +84,71 @@ type Target struct {
 //easyjson:skip
 type QueryArgs struct {
 	Args
-	MaxDataPoints uint64 `json:"maxDataPoints"`
+	MaxDataPoints uint64               `json:"maxDataPoints"`
+	Interval      string               `json:"interval"`     // interval between data points, as requested by the panel (e.g. "1m"). See IntervalDuration.
+	IntervalMS    int64                `json:"intervalMs"`   // interval between data points, in milliseconds.
+	ScopedVars    map[string]ScopedVar `json:"scopedVars"`   // template variables that apply to the query (e.g. "__interval").
+	Timezone      string               `json:"timezone"`     // timezone of the dashboard (e.g. "utc", "browser" or "Europe/Brussels"). See Location.
+	PanelID       int                  `json:"panelId"`      // ID of the panel that sent the query.
+	DashboardUID  string               `json:"dashboardUID"` // UID of the dashboard that sent the query.
+}
+
+// ScopedVar is the value of a template variable that applies to a query.
+//
+//easyjson:skip
+type ScopedVar struct {
+	Text  interface{} `json:"text"`
+	Value interface{} `json:"value"`
+}
+
+// IntervalDuration returns the interval between data points requested by the panel. If the request does not specify
+// a valid interval, IntervalDuration returns zero.
+func (q QueryArgs) IntervalDuration() time.Duration {
+	if q.IntervalMS > 0 {
+		return time.Duration(q.IntervalMS) * time.Millisecond
+	}
+	interval, _ := parseInterval(q.Interval)
+	return interval
+}
+
+var intervalUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// parseInterval parses a Grafana interval, i.e. a number followed by a unit (ms, s, m, h, d, w or y).
+func parseInterval(interval string) (time.Duration, error) {
+	index := strings.IndexFunc(interval, func(r rune) bool { return r < '0' || r > '9' })
+	if index <= 0 {
+		return 0, fmt.Errorf("invalid interval: '%s'", interval)
+	}
+	unit, ok := intervalUnits[interval[index:]]
+	if !ok {
+		return 0, fmt.Errorf("invalid interval: '%s'", interval)
+	}
+	value, err := strconv.Atoi(interval[:index])
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval: '%s': %w", interval, err)
+	}
+	return time.Duration(value) * unit, nil
+}
+
+// Location returns the timezone of the dashboard that sent the query. As the browser's timezone is not known to the server,
+// "browser" resolves to the server's local timezone. If the request does not specify a timezone, Location returns UTC.
+func (q QueryArgs) Location() (*time.Location, error) {
+	switch q.Timezone {
+	case "", "utc", "UTC":
+		return time.UTC, nil
+	case "browser":
+		return time.Local, nil
+	default:
+		return time.LoadLocation(q.Timezone)
+	}
 }
 
 // UnmarshalJSON unmarshalls a QueryRequest from JSON
@@ -59,15 +177,58 @@ type DataPoint struct {
 	Value     float64
 }
 
-// MarshalJSON converts a DataPoint to JSON.
+// MarshalJSON converts a DataPoint to JSON. NaN and infinite values are not valid JSON and are sent as null,
+// so Grafana shows a gap. See NaNPolicy for alternatives.
 func (d DataPoint) MarshalJSON() ([]byte, error) {
 	return []byte(`[` +
-			strconv.FormatFloat(d.Value, 'f', -1, 64) + `,` +
+			formatValue(d.Value) + `,` +
 			strconv.FormatInt(d.Timestamp.UnixMilli(), 10) +
 			`]`),
 		nil
 }
 
+// NullableTimeSeriesResponse is a timeseries response that may have missing values. Grafana shows a gap for each missing value.
+//
+//easyjson:skip
+type NullableTimeSeriesResponse struct {
+	Target     string              `json:"target"`
+	DataPoints []NullableDataPoint `json:"datapoints"`
+}
+
+// MarshalJSON converts a NullableTimeSeriesResponse to JSON.
+func (r NullableTimeSeriesResponse) MarshalJSON() ([]byte, error) {
+	type r2 NullableTimeSeriesResponse
+	return json.Marshal(r2(r))
+}
+
+// NullableDataPoint contains one entry of a NullableTimeSeriesResponse. A nil Value is a missing value and is sent as null.
+//
+//easyjson:skip
+type NullableDataPoint struct {
+	Timestamp time.Time
+	Value     *float64
+}
+
+// MarshalJSON converts a NullableDataPoint to JSON.
+func (d NullableDataPoint) MarshalJSON() ([]byte, error) {
+	value := "null"
+	if d.Value != nil {
+		value = formatValue(*d.Value)
+	}
+	return []byte(`[` +
+			value + `,` +
+			strconv.FormatInt(d.Timestamp.UnixMilli(), 10) +
+			`]`),
+		nil
+}
+
+func formatValue(value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "null"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
 // TableResponse is returned by a TableQuery, i.e. a slice of Column structures.
 //
 //easyjson:skip
@@ -76,8 +237,8 @@ type TableResponse struct {
 }
 
 // Column is a column returned by a TableQuery.  Text holds the column's header,
-// Data holds the slice of values and should be a TimeColumn, a StringColumn
-// or a NumberColumn.
+// Data holds the slice of values and should be one of the column types below,
+// e.g. a TimeColumn, a StringColumn or a NumberColumn.
 type Column struct {
 	Text string
 	Data interface{}
@@ -89,9 +250,30 @@ type TimeColumn []time.Time
 // StringColumn holds a slice of string values (one per row).
 type StringColumn []string
 
-// NumberColumn holds a slice of number values (one per row).
+// NumberColumn holds a slice of number values (one per row). NaN and infinite values are sent as null.
 type NumberColumn []float64
 
+// IntColumn holds a slice of integer values (one per row).
+type IntColumn []int64
+
+// BoolColumn holds a slice of boolean values (one per row).
+type BoolColumn []bool
+
+// NullableTimeColumn holds a slice of time.Time values (one per row). A nil value is sent as null.
+type NullableTimeColumn []*time.Time
+
+// NullableStringColumn holds a slice of string values (one per row). A nil value is sent as null.
+type NullableStringColumn []*string
+
+// NullableNumberColumn holds a slice of number values (one per row). A nil value is sent as null.
+type NullableNumberColumn []*float64
+
+// NullableIntColumn holds a slice of integer values (one per row). A nil value is sent as null.
+type NullableIntColumn []*int64
+
+// NullableBoolColumn holds a slice of boolean values (one per row). A nil value is sent as null.
+type NullableBoolColumn []*bool
+
 type tableResponse struct {
 	Type    string                `json:"type"`
 	Columns []tableResponseColumn `json:"columns"`
@@ -107,46 +289,80 @@ type tableResponseRow []interface{}
 
 // MarshalJSON converts a TableResponse to JSON.
 func (t TableResponse) MarshalJSON() (output []byte, err error) {
-	var colTypes []string
-	var rowCount int
-
-	if colTypes, rowCount, err = t.getColumnDetails(); err == nil {
-		output, err = easyjson.Marshal(tableResponse{
-			Type:    "table",
-			Columns: t.buildColumns(colTypes),
-			Rows:    t.buildRows(rowCount),
-		})
+	if err = t.Validate(); err != nil {
+		return nil, err
 	}
 
-	return output, err
+	colTypes, rowCount := t.getColumnDetails()
+	return easyjson.Marshal(tableResponse{
+		Type:    "table",
+		Columns: t.buildColumns(colTypes),
+		Rows:    t.buildRows(rowCount),
+	})
 }
 
-func (t TableResponse) getColumnDetails() (colTypes []string, rowCount int, err error) {
+// Validate checks that each column holds a supported column type and that all columns have the same number of rows.
+// The returned error describes each invalid column.
+func (t TableResponse) Validate() error {
+	var problems []string
+	rowCount := -1
 	for _, entry := range t.Columns {
-		var dataCount int
-		switch data := entry.Data.(type) {
-		case TimeColumn:
-			colTypes = append(colTypes, "time")
-			dataCount = len(data)
-		case StringColumn:
-			colTypes = append(colTypes, "string")
-			dataCount = len(data)
-		case NumberColumn:
-			colTypes = append(colTypes, "number")
-			dataCount = len(data)
+		_, dataCount, ok := getColumnType(entry.Data)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("column '%s': unsupported data type %T", entry.Text, entry.Data))
+			continue
 		}
-
-		if rowCount == 0 {
+		if rowCount == -1 {
 			rowCount = dataCount
+		} else if dataCount != rowCount {
+			problems = append(problems, fmt.Sprintf("column '%s': %d rows, expected %d", entry.Text, dataCount, rowCount))
 		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid table response: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
 
-		if dataCount != rowCount {
-			return colTypes, rowCount, errors.New("error building table query output: all columns must have the same number of rows")
+// getColumnDetails returns the type of each column and the number of rows. The TableResponse must be valid.
+func (t TableResponse) getColumnDetails() (colTypes []string, rowCount int) {
+	for index, entry := range t.Columns {
+		colType, dataCount, _ := getColumnType(entry.Data)
+		colTypes = append(colTypes, colType)
+		if index == 0 {
+			rowCount = dataCount
 		}
 	}
 	return
 }
 
+// getColumnType returns the table response type and the number of rows of a column's data
+func getColumnType(data interface{}) (colType string, rowCount int, ok bool) {
+	switch data := data.(type) {
+	case TimeColumn:
+		return "time", len(data), true
+	case NullableTimeColumn:
+		return "time", len(data), true
+	case StringColumn:
+		return "string", len(data), true
+	case NullableStringColumn:
+		return "string", len(data), true
+	case NumberColumn:
+		return "number", len(data), true
+	case NullableNumberColumn:
+		return "number", len(data), true
+	case IntColumn:
+		return "number", len(data), true
+	case NullableIntColumn:
+		return "number", len(data), true
+	case BoolColumn:
+		return "boolean", len(data), true
+	case NullableBoolColumn:
+		return "boolean", len(data), true
+	}
+	return "", 0, false
+}
+
 func (t TableResponse) buildColumns(colTypes []string) []tableResponseColumn {
 	columns := make([]tableResponseColumn, len(colTypes))
 	for index, colType := range colTypes {
@@ -166,13 +382,45 @@ func (t TableResponse) buildRows(rowCount int) []tableResponseRow {
 			switch data := entry.Data.(type) {
 			case TimeColumn:
 				newRow[column] = data[row]
+			case NullableTimeColumn:
+				newRow[column] = nullable(data[row])
 			case StringColumn:
 				newRow[column] = data[row]
+			case NullableStringColumn:
+				newRow[column] = nullable(data[row])
 			case NumberColumn:
+				newRow[column] = number(data[row])
+			case NullableNumberColumn:
+				if data[row] != nil {
+					newRow[column] = number(*data[row])
+				}
+			case IntColumn:
+				newRow[column] = data[row]
+			case NullableIntColumn:
+				newRow[column] = nullable(data[row])
+			case BoolColumn:
 				newRow[column] = data[row]
+			case NullableBoolColumn:
+				newRow[column] = nullable(data[row])
 			}
 		}
 		rows[row] = newRow
 	}
 	return rows
 }
+
+// number returns the value of a number cell, or nil for NaN and infinite values, which are not valid JSON
+func number(value float64) interface{} {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil
+	}
+	return value
+}
+
+// nullable returns the value of a nullable cell, or nil if the cell is null
+func nullable[T any](value *T) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
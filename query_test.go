@@ -7,6 +7,7 @@ import (
 	"github.com/clambin/simplejson/v6"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,10 +19,19 @@ func TestRequests(t *testing.T) {
 	input := `{
 	"maxDataPoints": 100,
 	"interval": "1h",
+	"intervalMs": 3600000,
 	"range": {
 		"from": "2020-01-01T00:00:00.000Z",
-		"to": "2020-12-31T00:00:00.000Z"
+		"to": "2020-12-31T00:00:00.000Z",
+		"raw": { "from": "now-1y", "to": "now" }
 	},
+	"scopedVars": {
+		"__interval": { "text": "1h", "value": "1h" },
+		"__interval_ms": { "text": "3600000", "value": 3600000 }
+	},
+	"timezone": "Europe/Brussels",
+	"panelId": 2,
+	"dashboardUID": "abc",
 	"targets": [
 		{ "target": "A", "type": "dataserie" },
 		{ "target": "B", "type": "table" }
@@ -33,10 +43,21 @@ func TestRequests(t *testing.T) {
 	err := json.Unmarshal([]byte(input), &output)
 	require.NoError(t, err)
 	assert.Equal(t, uint64(100), output.MaxDataPoints)
-	// assert.Equal(t, server.QueryRequestDuration(1*time.Hour), output.Interval)
-	// assert.Equal(t, 1*time.Hour, time.duration(output.Interval))
+	assert.Equal(t, "1h", output.Interval)
+	assert.Equal(t, int64(3600000), output.IntervalMS)
+	assert.Equal(t, time.Hour, output.IntervalDuration())
 	assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), output.Range.From)
 	assert.Equal(t, time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC), output.Range.To)
+	assert.Equal(t, simplejson.RawRange{From: "now-1y", To: "now"}, output.Range.Raw)
+	assert.Equal(t, map[string]simplejson.ScopedVar{
+		"__interval":    {Text: "1h", Value: "1h"},
+		"__interval_ms": {Text: "3600000", Value: 3600000.0},
+	}, output.ScopedVars)
+	assert.Equal(t, 2, output.PanelID)
+	assert.Equal(t, "abc", output.DashboardUID)
+	location, err := output.Location()
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Brussels", location.String())
 	require.Len(t, output.Targets, 2)
 	assert.Equal(t, "A", output.Targets[0].Name)
 	assert.Equal(t, "dataserie", output.Targets[0].Type)
@@ -44,6 +65,105 @@ func TestRequests(t *testing.T) {
 	assert.Equal(t, "table", output.Targets[1].Type)
 }
 
+func TestQueryArgs_IntervalDuration(t *testing.T) {
+	testCases := []struct {
+		args simplejson.QueryArgs
+		want time.Duration
+	}{
+		{args: simplejson.QueryArgs{IntervalMS: 30000, Interval: "1h"}, want: 30 * time.Second},
+		{args: simplejson.QueryArgs{Interval: "500ms"}, want: 500 * time.Millisecond},
+		{args: simplejson.QueryArgs{Interval: "15s"}, want: 15 * time.Second},
+		{args: simplejson.QueryArgs{Interval: "5m"}, want: 5 * time.Minute},
+		{args: simplejson.QueryArgs{Interval: "2h"}, want: 2 * time.Hour},
+		{args: simplejson.QueryArgs{Interval: "1d"}, want: 24 * time.Hour},
+		{args: simplejson.QueryArgs{Interval: "1w"}, want: 7 * 24 * time.Hour},
+		{args: simplejson.QueryArgs{Interval: "1y"}, want: 365 * 24 * time.Hour},
+		{args: simplejson.QueryArgs{Interval: "1x"}, want: 0},
+		{args: simplejson.QueryArgs{Interval: "m"}, want: 0},
+		{args: simplejson.QueryArgs{}, want: 0},
+	}
+
+	for _, tt := range testCases {
+		assert.Equal(t, tt.want, tt.args.IntervalDuration(), tt.args.Interval)
+	}
+}
+
+func TestQueryArgs_Location(t *testing.T) {
+	testCases := []struct {
+		timezone string
+		pass     bool
+		want     *time.Location
+	}{
+		{timezone: "", pass: true, want: time.UTC},
+		{timezone: "utc", pass: true, want: time.UTC},
+		{timezone: "browser", pass: true, want: time.Local},
+		{timezone: "not a timezone", pass: false},
+	}
+
+	for _, tt := range testCases {
+		location, err := simplejson.QueryArgs{Timezone: tt.timezone}.Location()
+		if !tt.pass {
+			assert.Error(t, err, tt.timezone)
+			continue
+		}
+		require.NoError(t, err, tt.timezone)
+		assert.Equal(t, tt.want, location, tt.timezone)
+	}
+}
+
+func TestTarget_DecodePayload(t *testing.T) {
+	type payload struct {
+		Host string `json:"host"`
+	}
+
+	testCases := []struct {
+		name    string
+		request string
+		pass    bool
+		want    payload
+	}{
+		{name: "payload", request: `{"target": "A", "payload": {"host": "host-01"}}`, pass: true, want: payload{Host: "host-01"}},
+		{name: "payload as string", request: `{"target": "A", "payload": "{\"host\": \"host-01\"}"}`, pass: true, want: payload{Host: "host-01"}},
+		{name: "data", request: `{"target": "A", "data": {"host": "host-02"}}`, pass: true, want: payload{Host: "host-02"}},
+		{name: "payload before data", request: `{"target": "A", "payload": {"host": "host-01"}, "data": {"host": "host-02"}}`, pass: true, want: payload{Host: "host-01"}},
+		{name: "empty", request: `{"target": "A", "payload": ""}`, pass: true},
+		{name: "none", request: `{"target": "A"}`, pass: true},
+		{name: "invalid", request: `{"target": "A", "payload": "{"}`, pass: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var target simplejson.Target
+			require.NoError(t, json.Unmarshal([]byte(tt.request), &target))
+
+			var p payload
+			err := target.DecodePayload(&p)
+			if !tt.pass {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p)
+		})
+	}
+}
+
+func TestTarget_UnmarshalJSON(t *testing.T) {
+	var request simplejson.QueryRequest
+	err := json.Unmarshal([]byte(`{ "targets": [
+		{ "target": "A", "refId": "A", "hide": true, "datasource": { "type": "simpod-json-datasource", "uid": "abc" } },
+		{ "target": "B", "refId": "B", "datasource": "SimpleJSON" }
+	] }`), &request)
+	require.NoError(t, err)
+	require.Len(t, request.Targets, 2)
+	assert.Equal(t, "A", request.Targets[0].RefID)
+	assert.True(t, request.Targets[0].Hide)
+	assert.Equal(t, simplejson.Datasource{Type: "simpod-json-datasource", UID: "abc"}, request.Targets[0].Datasource)
+	assert.Equal(t, "B", request.Targets[1].RefID)
+	assert.False(t, request.Targets[1].Hide)
+	assert.Equal(t, simplejson.Datasource{Name: "SimpleJSON"}, request.Targets[1].Datasource)
+}
+
 func TestResponse(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -74,6 +194,41 @@ func TestResponse(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "types",
+			pass: true,
+			response: simplejson.TableResponse{
+				Columns: []simplejson.Column{
+					{Text: "Time", Data: simplejson.TimeColumn{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)}},
+					{Text: "Count", Data: simplejson.IntColumn{42, 43}},
+					{Text: "Up", Data: simplejson.BoolColumn{true, false}},
+				},
+			},
+		},
+		{
+			name: "nullable",
+			pass: true,
+			response: simplejson.TableResponse{
+				Columns: []simplejson.Column{
+					{Text: "Time", Data: simplejson.NullableTimeColumn{pointer(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)), nil}},
+					{Text: "Label", Data: simplejson.NullableStringColumn{nil, pointer("bar")}},
+					{Text: "Series A", Data: simplejson.NullableNumberColumn{pointer(42.0), nil}},
+					{Text: "Count", Data: simplejson.NullableIntColumn{nil, pointer(int64(43))}},
+					{Text: "Up", Data: simplejson.NullableBoolColumn{pointer(true), nil}},
+				},
+			},
+		},
+		{
+			name: "nan",
+			pass: true,
+			response: simplejson.TableResponse{
+				Columns: []simplejson.Column{
+					{Text: "Time", Data: simplejson.TimeColumn{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)}},
+					{Text: "Series A", Data: simplejson.NumberColumn{math.NaN(), math.Inf(1)}},
+					{Text: "Series B", Data: simplejson.NullableNumberColumn{pointer(math.NaN()), pointer(1.0)}},
+				},
+			},
+		},
 		{
 			name:     "combined",
 			pass:     true,
@@ -91,6 +246,16 @@ func TestResponse(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "unsupported",
+			pass: false,
+			response: simplejson.TableResponse{
+				Columns: []simplejson.Column{
+					{Text: "Time", Data: simplejson.TimeColumn{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}},
+					{Text: "Series A", Data: []float32{42}},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -126,6 +291,67 @@ func TestResponse(t *testing.T) {
 	}
 }
 
+func pointer[T any](value T) *T {
+	return &value
+}
+
+func TestTableResponse_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		columns []simplejson.Column
+		wantErr string
+	}{
+		{
+			name: "valid",
+			columns: []simplejson.Column{
+				{Text: "Time", Data: simplejson.TimeColumn{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}},
+				{Text: "Series A", Data: simplejson.NumberColumn{42}},
+			},
+		},
+		{
+			name: "unsupported type",
+			columns: []simplejson.Column{
+				{Text: "Time", Data: simplejson.TimeColumn{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}},
+				{Text: "Series A", Data: []float64{42}},
+			},
+			wantErr: "invalid table response: column 'Series A': unsupported data type []float64",
+		},
+		{
+			name: "row count",
+			columns: []simplejson.Column{
+				{Text: "Time", Data: simplejson.TimeColumn{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}},
+				{Text: "Label", Data: simplejson.StringColumn{"foo", "bar"}},
+				{Text: "Series A", Data: simplejson.NumberColumn{42}},
+				{Text: "Series B", Data: simplejson.NumberColumn{}},
+			},
+			wantErr: "invalid table response: column 'Label': 2 rows, expected 1; column 'Series B': 0 rows, expected 1",
+		},
+		{
+			name: "combined",
+			columns: []simplejson.Column{
+				{Text: "Time", Data: nil},
+				{Text: "Label", Data: simplejson.StringColumn{"foo", "bar"}},
+				{Text: "Series A", Data: simplejson.NumberColumn{42}},
+			},
+			wantErr: "invalid table response: column 'Time': unsupported data type <nil>; column 'Series A': 1 rows, expected 2",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			response := simplejson.TableResponse{Columns: tt.columns}
+			err := response.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+			_, err = response.MarshalJSON()
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
 type combinedResponse struct {
 	responses []interface{}
 }
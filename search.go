@@ -0,0 +1,95 @@
+package simplejson
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// SearchValue is a text/value pair returned by the /search endpoint. Grafana shows the Text in a template variable's
+// dropdown, while the Value is used in queries.
+type SearchValue struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+// search returns all targets matching the search term. Handlers that implement the SearchValues or Search endpoint determine
+// their own matching targets. For all other handlers, search returns the targets that contain the search term.
+//
+// If the Server is configured with WithSearchValues, search returns all results as a slice of SearchValue. Otherwise,
+// it returns a slice of strings, holding the Value of any text/value pairs.
+func (s *Server) search(ctx context.Context, term string) (interface{}, error) {
+	var targets []string
+	var values []SearchValue
+	for _, h := range s.getHandlers() {
+		endpoints := h.handler.Endpoints()
+		switch {
+		case endpoints.SearchValues != nil:
+			newValues, err := searchHandler(ctx, s, h.target, endpoints.SearchValues, term)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, newValues...)
+		case endpoints.Search != nil:
+			newTargets, err := searchHandler(ctx, s, h.target, endpoints.Search, term)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, newTargets...)
+		default:
+			for _, candidate := range h.targets(ctx) {
+				if strings.Contains(candidate, term) {
+					targets = append(targets, candidate)
+				}
+			}
+		}
+	}
+
+	if !s.searchValues {
+		for _, value := range values {
+			targets = append(targets, value.Value)
+		}
+		return uniqueSorted(targets), nil
+	}
+	for _, target := range targets {
+		values = append(values, SearchValue{Text: target, Value: target})
+	}
+	return uniqueSortedValues(values), nil
+}
+
+func searchHandler[T any](ctx context.Context, s *Server, target string, f func(context.Context, string) ([]T, error), term string) ([]T, error) {
+	ctx, cancel := s.withTimeout(ctx, target)
+	defer cancel()
+	results, err := f(ctx, term)
+	if err != nil {
+		err = timeoutError(ctx, target, err)
+	}
+	return results, err
+}
+
+func uniqueSorted(values []string) []string {
+	sort.Strings(values)
+	unique := values[:0]
+	for _, v := range values {
+		if len(unique) == 0 || v != unique[len(unique)-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+func uniqueSortedValues(values []SearchValue) []SearchValue {
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Text != values[j].Text {
+			return values[i].Text < values[j].Text
+		}
+		return values[i].Value < values[j].Value
+	})
+	unique := values[:0]
+	for _, v := range values {
+		if len(unique) == 0 || v != unique[len(unique)-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
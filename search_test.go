@@ -0,0 +1,118 @@
+package simplejson_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/clambin/simplejson/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSearch_Term(t *testing.T) {
+	r := simplejson.New(map[string]simplejson.Handler{
+		"cpu.host-01": handlers["A"],
+		"cpu.host-02": handlers["A"],
+		"mem.host-01": handlers["B"],
+		"dynamic":     searchHandler{"host-01", "host-02", "host-03"},
+	})
+
+	testCases := []struct {
+		name    string
+		request string
+		code    int
+		want    string
+	}{
+		{name: "empty", request: ``, code: http.StatusOK, want: `["cpu.host-01","cpu.host-02","host-01","host-02","host-03","mem.host-01"]`},
+		{name: "empty term", request: `{"target": ""}`, code: http.StatusOK, want: `["cpu.host-01","cpu.host-02","host-01","host-02","host-03","mem.host-01"]`},
+		{name: "prefix", request: `{"target": "cpu"}`, code: http.StatusOK, want: `["cpu.host-01","cpu.host-02"]`},
+		{name: "substring", request: `{"target": "host-01"}`, code: http.StatusOK, want: `["cpu.host-01","host-01","mem.host-01"]`},
+		{name: "none", request: `{"target": "disk"}`, code: http.StatusOK, want: `null`},
+		{name: "handler failure", request: `{"target": "fail"}`, code: http.StatusInternalServerError, want: "failed to process request: search failed\n"},
+		{name: "invalid", request: `{"target": `, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/search", bytes.NewBufferString(tt.request))
+			r.ServeHTTP(w, req)
+			require.Equal(t, tt.code, w.Code)
+			if tt.want != "" {
+				assert.Equal(t, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+// searchHandler returns the values that contain the search term
+type searchHandler []string
+
+func (h searchHandler) Endpoints() simplejson.Endpoints {
+	return simplejson.Endpoints{
+		Search: func(_ context.Context, term string) (values []string, err error) {
+			if term == "fail" {
+				return nil, errors.New("search failed")
+			}
+			for _, v := range h {
+				if strings.Contains(v, term) {
+					values = append(values, v)
+				}
+			}
+			return values, nil
+		},
+	}
+}
+
+func TestSearch_Values(t *testing.T) {
+	h := map[string]simplejson.Handler{
+		"A": handlers["A"],
+		"hosts": searchValuesHandler{
+			{Text: "host 1", Value: "id-1"},
+			{Text: "host 2", Value: "id-2"},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		options []simplejson.Option
+		request string
+		want    string
+	}{
+		{name: "strings", request: `{"target": ""}`, want: `["A","id-1","id-2"]`},
+		{name: "strings - only values", request: `{"target": "2"}`, want: `["id-2"]`},
+		{name: "values", options: []simplejson.Option{simplejson.WithSearchValues{}}, request: `{"target": ""}`, want: `[{"text":"A","value":"A"},{"text":"host 1","value":"id-1"},{"text":"host 2","value":"id-2"}]`},
+		{name: "values - only targets", options: []simplejson.Option{simplejson.WithSearchValues{}}, request: `{"target": "A"}`, want: `[{"text":"A","value":"A"}]`},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := simplejson.New(h, tt.options...)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/search", bytes.NewBufferString(tt.request))
+			r.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.want, w.Body.String())
+		})
+	}
+}
+
+// searchValuesHandler returns the text/value pairs whose text contains the search term
+type searchValuesHandler []simplejson.SearchValue
+
+func (h searchValuesHandler) Endpoints() simplejson.Endpoints {
+	return simplejson.Endpoints{
+		SearchValues: func(_ context.Context, term string) (values []simplejson.SearchValue, err error) {
+			for _, v := range h {
+				if strings.Contains(v.Text, term) {
+					values = append(values, v)
+				}
+			}
+			return values, nil
+		},
+	}
+}
@@ -1,7 +1,13 @@
 package simplejson
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/clambin/go-common/httpserver/middleware"
 	"github.com/go-chi/chi/v5"
@@ -14,18 +20,38 @@ import (
 // Server receives SimpleJSON requests from Grafana and dispatches them to the handler that serves the specified target.
 type Server struct {
 	chi.Router
-	Handlers          map[string]Handler
-	prometheusMetrics *middleware.PrometheusMetrics
-	queryMetrics      *QueryMetrics
-	logger            *slog.Logger
+	// Handlers holds the handlers passed to New. Targets that weren't added through Register are looked up in Handlers.
+	//
+	// Deprecated: use Register and Unregister to change the Server's handlers. Handlers must not be modified while the
+	// Server is processing requests.
+	Handlers               map[string]Handler
+	lock                   sync.RWMutex
+	handlers               map[string]Handler
+	patterns               []targetPattern
+	prometheusMetrics      *middleware.PrometheusMetrics
+	queryMetrics           *QueryMetrics
+	searchValues           bool
+	maxConcurrentQueries   int
+	partialResults         bool
+	timeout                time.Duration
+	targetTimeouts         map[string]time.Duration
+	downsampling           *DownsampleAlgorithm
+	nanPolicy              NaNPolicy
+	annotationErrorPolicy  AnnotationErrorPolicy
+	filterAnnotationsRange bool
+	filterAnnotationsTags  bool
+	httpHandlers           []WithHTTPHandler
+	logger                 *slog.Logger
 }
 
 var _ prometheus.Collector = &Server{}
 var _ http.Handler = &Server{}
 
+// New creates a Server that serves the provided handlers. Handlers can be added or removed later through Register and Unregister.
 func New(handlers map[string]Handler, options ...Option) *Server {
 	s := Server{
 		Handlers: handlers,
+		handlers: make(map[string]Handler),
 		Router:   chi.NewRouter(),
 		logger:   slog.Default(),
 	}
@@ -49,11 +75,101 @@ func New(handlers map[string]Handler, options ...Option) *Server {
 		})
 		r.Post("/tag-keys", s.TagKeys)
 		r.Post("/tag-values", s.TagValues)
+		r.Post("/metrics", s.Metrics)
+		r.Post("/metric-payload-options", s.MetricPayloadOptions)
+		r.Post("/variable", s.Variable)
+		for _, h := range s.httpHandlers {
+			r.Mount(h.Path, h.Handler)
+		}
 	})
 
 	return &s
 }
 
+// Register adds the handler for the specified target. If the target already has a handler, it is replaced.
+// Register is safe to call while the Server is processing requests.
+func (s *Server) Register(target string, handler Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.handlers[target] = handler
+}
+
+// Unregister removes the handler for the specified target. Unregister is safe to call while the Server is processing requests.
+// If the target was passed to New, it is also removed from Handlers. To remove a pattern handler, use UnregisterPattern.
+func (s *Server) Unregister(target string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.handlers, target)
+	delete(s.Handlers, target)
+}
+
+// getHandler returns the handler for the specified target. If the target is served by a pattern, getHandler also returns
+// the parameters captured by the pattern.
+func (s *Server) getHandler(target string) (Handler, map[string]string, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if handler, ok := s.handlers[target]; ok {
+		return handler, nil, true
+	}
+	if handler, ok := s.Handlers[target]; ok {
+		return handler, nil, true
+	}
+	for _, p := range s.patterns {
+		if params, ok := p.match(target); ok {
+			return p.handler, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+// targetHandler is a registered handler and the target (or pattern) it serves.
+type targetHandler struct {
+	target  string
+	handler Handler
+	pattern *targetPattern // nil if the handler serves a single target
+}
+
+// targets returns the concrete targets served by the handler. For a pattern handler, these are the targets returned
+// by its Targets endpoint that match the pattern.
+func (h targetHandler) targets(ctx context.Context) []string {
+	if h.pattern == nil {
+		return []string{h.target}
+	}
+	f := h.handler.Endpoints().Targets
+	if f == nil {
+		return nil
+	}
+	var targets []string
+	for _, target := range f(ctx) {
+		if _, ok := h.pattern.match(target); ok {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// getHandlers returns a snapshot of all registered handlers, sorted by target name.
+func (s *Server) getHandlers() []targetHandler {
+	s.lock.RLock()
+	handlers := make([]targetHandler, 0, len(s.handlers)+len(s.Handlers)+len(s.patterns))
+	for target, handler := range s.handlers {
+		handlers = append(handlers, targetHandler{target: target, handler: handler})
+	}
+	for target, handler := range s.Handlers {
+		if _, ok := s.handlers[target]; !ok {
+			handlers = append(handlers, targetHandler{target: target, handler: handler})
+		}
+	}
+	for index := range s.patterns {
+		p := s.patterns[index]
+		handlers = append(handlers, targetHandler{target: p.pattern, handler: p.handler, pattern: &p})
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(handlers, func(i, j int) bool { return handlers[i].target < handlers[j].target })
+	return handlers
+}
+
 // Describe implements the prometheus.Collector interface
 func (s *Server) Describe(descs chan<- *prometheus.Desc) {
 	if s.prometheusMetrics != nil {
@@ -73,3 +189,25 @@ func (s *Server) Collect(metrics chan<- prometheus.Metric) {
 		s.queryMetrics.Collect(metrics)
 	}
 }
+
+// withTimeout returns a context that is bounded by the timeout configured for the target. If no timeout is configured,
+// the context is returned unchanged.
+func (s *Server) withTimeout(ctx context.Context, target string) (context.Context, context.CancelFunc) {
+	timeout, ok := s.targetTimeouts[target]
+	if !ok {
+		timeout = s.timeout
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// timeoutError returns a timeout error if the target's context expired while the handler was processing the request.
+// Otherwise, it returns the original error.
+func timeoutError(ctx context.Context, target string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("target '%s' timed out: %w", target, context.DeadlineExceeded)
+	}
+	return err
+}
@@ -15,6 +15,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -34,7 +37,7 @@ func TestNewRouter(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	}
 
-	for _, path := range []string{"/search", "/query", "/annotations", "/tag-keys", "/tag-values"} {
+	for _, path := range []string{"/search", "/query", "/annotations", "/tag-keys", "/tag-values", "/metrics", "/variable"} {
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodPost, path, nil)
 		r.ServeHTTP(w, req)
@@ -83,6 +86,29 @@ func TestNewRouter_PrometheusMetrics(t *testing.T) {
 	assert.Equal(t, 2, n)
 }
 
+func TestWithHTTPHandler(t *testing.T) {
+	r := simplejson.New(nil,
+		simplejson.WithHTTPMetrics{Option: middleware.PrometheusMetricsOptions{Application: "snafu"}},
+		simplejson.WithHTTPHandler{Path: "/custom", Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("Hello"))
+		})},
+	)
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/custom", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Hello", w.Body.String())
+
+	// the request is recorded by the Server's HTTP metrics
+	n, err := testutil.GatherAndCount(reg)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
 func TestNewRouter_QueryMetrics(t *testing.T) {
 	r := simplejson.New(handlers, simplejson.WithQueryMetrics{})
 
@@ -101,6 +127,81 @@ func TestNewRouter_QueryMetrics(t *testing.T) {
 	assert.Equal(t, 1, n)
 }
 
+func TestServer_Handlers(t *testing.T) {
+	r := simplejson.New(nil)
+	r.Handlers = map[string]simplejson.Handler{"A": handlers["A"]}
+	r.Handlers["B"] = handlers["B"]
+	r.Register("C", handlers["C"])
+
+	query := func(target string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [ { "target": "`+target+`" } ] }`))
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, target := range []string{"A", "B", "C"} {
+		assert.Equal(t, http.StatusOK, query(target), target)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/search", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `["A","B","C"]`, strings.TrimSpace(w.Body.String()))
+
+	r.Unregister("A")
+	assert.NotContains(t, r.Handlers, "A")
+	assert.Equal(t, http.StatusInternalServerError, query("A"))
+}
+
+func TestServer_Register(t *testing.T) {
+	r := simplejson.New(nil)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			target := strconv.Itoa(i)
+			r.Register(target, handlers["A"])
+			if i%2 == 1 {
+				r.Unregister(target)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			for _, path := range []string{"/search", "/annotations", "/tag-keys"} {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest(http.MethodPost, path, nil)
+				r.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+		}
+	}()
+	wg.Wait()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [ { "target": "98" } ] }`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	r.Unregister("98")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{ "targets": [ { "target": "98" } ] }`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/search", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"96"`)
+	assert.NotContains(t, w.Body.String(), `"97"`)
+}
+
 //
 //
 // Test Handler
@@ -221,3 +322,10 @@ func (handler *testHandler) TagValues(_ context.Context, tag string) (values []s
 	}
 	return
 }
+
+// queryHandler is a Handler that only implements the /query endpoint
+type queryHandler simplejson.QueryFunc
+
+func (q queryHandler) Endpoints() simplejson.Endpoints {
+	return simplejson.Endpoints{Query: simplejson.QueryFunc(q)}
+}